@@ -0,0 +1,41 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"paste.org.cn/paste/server/util"
+)
+
+// 缓存类型
+const (
+	TypeNone   = "none"   // 不使用缓存
+	TypeMemory = "memory" // 进程内 LRU 缓存
+)
+
+// Cache 接口定义了缓存的操作，值为序列化后的字节，便于接入 Redis 等外部缓存
+type Cache interface {
+	Get(ctx context.Context, key string) ([]byte, bool)
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration)
+	Delete(ctx context.Context, keys ...string)
+}
+
+// New 根据配置创建缓存
+func New(config util.CacheConfig) (Cache, error) {
+	switch config.Type {
+	case TypeNone:
+		return nop{}, nil
+	case TypeMemory:
+		return NewLRU(config.MaxEntries, int64(config.MaxSize)<<20), nil
+	default:
+		return nil, fmt.Errorf("不支持的缓存类型: %s", config.Type)
+	}
+}
+
+// nop 不缓存任何内容
+type nop struct{}
+
+func (nop) Get(context.Context, string) ([]byte, bool)         { return nil, false }
+func (nop) Set(context.Context, string, []byte, time.Duration) {}
+func (nop) Delete(context.Context, ...string)                  {}
@@ -0,0 +1,99 @@
+package cache
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+)
+
+// LRU 是有容量上限的进程内缓存，按条目数量和字节数淘汰最久未使用的条目
+type LRU struct {
+	mu         sync.Mutex
+	maxEntries int   // 最大条目数，0 表示不限制
+	maxBytes   int64 // 最大字节数，0 表示不限制
+	bytes      int64 // 当前占用的字节数
+	ll         *list.List
+	items      map[string]*list.Element
+}
+
+type lruEntry struct {
+	key      string
+	value    []byte
+	expireAt time.Time
+}
+
+// NewLRU 创建一个 LRU 缓存
+func NewLRU(maxEntries int, maxBytes int64) *LRU {
+	return &LRU{
+		maxEntries: maxEntries,
+		maxBytes:   maxBytes,
+		ll:         list.New(),
+		items:      make(map[string]*list.Element),
+	}
+}
+
+// Get 返回未过期的缓存值，并将其标记为最近使用
+func (c *LRU) Get(_ context.Context, key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	entry := elem.Value.(*lruEntry)
+	if time.Now().After(entry.expireAt) {
+		c.remove(elem)
+		return nil, false
+	}
+	c.ll.MoveToFront(elem)
+	return entry.value, true
+}
+
+// Set 写入缓存，ttl 不大于 0 或单个值超过字节上限时不缓存
+func (c *LRU) Set(_ context.Context, key string, value []byte, ttl time.Duration) {
+	if ttl <= 0 || (c.maxBytes > 0 && int64(len(value)) > c.maxBytes) {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.items[key]; ok {
+		c.remove(elem)
+	}
+	elem := c.ll.PushFront(&lruEntry{key: key, value: value, expireAt: time.Now().Add(ttl)})
+	c.items[key] = elem
+	c.bytes += int64(len(value))
+
+	// 超出容量时从最久未使用的一端淘汰
+	for (c.maxEntries > 0 && c.ll.Len() > c.maxEntries) || (c.maxBytes > 0 && c.bytes > c.maxBytes) {
+		c.remove(c.ll.Back())
+	}
+}
+
+// Delete 删除缓存
+func (c *LRU) Delete(_ context.Context, keys ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, key := range keys {
+		if elem, ok := c.items[key]; ok {
+			c.remove(elem)
+		}
+	}
+}
+
+// Len 返回当前缓存的条目数
+func (c *LRU) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.ll.Len()
+}
+
+func (c *LRU) remove(elem *list.Element) {
+	entry := c.ll.Remove(elem).(*lruEntry)
+	delete(c.items, entry.key)
+	c.bytes -= int64(len(entry.value))
+}
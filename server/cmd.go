@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"paste.org.cn/paste/server/db"
+	"paste.org.cn/paste/server/lang"
+	"paste.org.cn/paste/server/util"
+)
+
+// usage 输出命令行帮助信息
+func usage() {
+	fmt.Fprintf(flag.CommandLine.Output(), `Usage:
+  %[1]s [--config config.yaml]                启动服务
+  %[1]s [--config config.yaml] config check   校验配置并输出生效的配置（隐藏密钥）
+  %[1]s [--config config.yaml] migrate langtypes [--dry-run]
+                                              将已有分享的片段语言类型规范化（例如 golang、Go 改为 go，不支持的改为 plain）
+                                              运行中的服务在 cache.ttl 内仍可能返回缓存的旧值，需要立即生效时重启服务
+
+所有配置项都可以通过 %[2]s_ 前缀的环境变量覆盖，例如 storage.cloud.secret_key 对应 %[2]s_STORAGE_CLOUD_SECRET_KEY
+
+Flags:
+`, os.Args[0], util.EnvPrefix)
+	flag.PrintDefaults()
+}
+
+// runCommand 执行子命令，返回进程退出码
+func runCommand(args []string, configFile string) int {
+	switch {
+	case len(args) >= 2 && args[0] == "config" && args[1] == "check":
+		return configCheck(args[2:], configFile)
+	case len(args) >= 2 && args[0] == "migrate" && args[1] == "langtypes":
+		return migrateLangtypes(args[2:], configFile)
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command: %v\n\n", args)
+		flag.Usage()
+		return 2
+	}
+}
+
+// configCheck 校验配置，并以 JSON 格式输出隐藏了密钥的生效配置
+func configCheck(args []string, configFile string) int {
+	fs := flag.NewFlagSet("config check", flag.ContinueOnError)
+	fs.StringVar(&configFile, "config", configFile, "配置文件路径，默认在当前目录查找 config.yaml")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	cfg, used, err := util.ReadConfig(configFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 1
+	}
+	if used == "" {
+		used = "(none)"
+	}
+	fmt.Printf("# config file: %s\n", used)
+
+	out, err := json.MarshalIndent(cfg.Masked(), "", "  ")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 1
+	}
+	fmt.Println(string(out))
+
+	if err := cfg.Validate(); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 1
+	}
+	fmt.Fprintln(os.Stderr, "config ok")
+	return 0
+}
+
+// migrateLangtypes 将已有分享的片段语言类型转换为语言注册表中的规范 ID，不支持的语言类型改为纯文本
+func migrateLangtypes(args []string, configFile string) int {
+	fs := flag.NewFlagSet("migrate langtypes", flag.ContinueOnError)
+	fs.StringVar(&configFile, "config", configFile, "配置文件路径，默认在当前目录查找 config.yaml")
+	dryRun := fs.Bool("dry-run", false, "只统计需要修改的分享，不写入数据库")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	cfg, _, err := util.ReadConfig(configFile)
+	if err == nil {
+		err = cfg.Validate()
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 1
+	}
+
+	ctx := context.Background()
+	paste, err := db.NewPaste(ctx, cfg.Paste)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "connect mongodb: %v\n", err)
+		return 1
+	}
+	defer db.GetMongoClient().Disconnect(ctx)
+
+	result, err := paste.NormalizeLangtypes(ctx, func(langtype string) string {
+		if id, ok := lang.Normalize(langtype); ok {
+			return id
+		}
+		return lang.Plain
+	}, *dryRun)
+	// 中途失败时同样输出已经处理的结果，重新执行会跳过已经规范化的分享
+	out, _ := json.MarshalIndent(result, "", "  ")
+	fmt.Println(string(out))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 1
+	}
+	if *dryRun {
+		fmt.Fprintln(os.Stderr, "dry run, nothing changed")
+	} else if result.Updated > 0 {
+		// 分享内容缓存在服务进程的内存中，迁移命令无法使其失效
+		fmt.Fprintln(os.Stderr, "running servers may serve cached langtypes until cache.ttl expires, restart them to apply immediately")
+	}
+	return 0
+}
@@ -0,0 +1,147 @@
+package db
+
+import (
+	"context"
+	"errors"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"paste.org.cn/paste/server/proto"
+)
+
+// toBSON 将 PasteFilter 转换为 MongoDB 查询条件
+func (f PasteFilter) toBSON() bson.M {
+	filter := bson.M{}
+	if len(f.Keys) > 0 {
+		filter["key"] = bson.M{"$in": f.Keys}
+	}
+	if f.ClientIP != "" {
+		filter["client_ip"] = f.ClientIP
+	}
+	if !f.From.IsZero() || !f.To.IsZero() {
+		createdAt := bson.M{}
+		if !f.From.IsZero() {
+			createdAt["$gte"] = f.From
+		}
+		if !f.To.IsZero() {
+			createdAt["$lt"] = f.To
+		}
+		filter["created_at"] = createdAt
+	}
+	return filter
+}
+
+// Find 方法按条件查询分享内容，按创建时间倒序返回，并返回满足条件的总数
+func (p _Paste) Find(ctx context.Context, filter PasteFilter) (entries []PasteEntry, total int64, err error) {
+	query := filter.toBSON()
+
+	total, err = p.Collection.CountDocuments(ctx, query)
+	if err != nil {
+		return nil, 0, observeErr("count", err)
+	}
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "created_at", Value: -1}}).
+		SetSkip(filter.Skip).
+		// 管理接口只需要摘要信息，不加载图片的 Base64 内容
+		SetProjection(bson.M{"images.base64_content": 0})
+	if filter.Limit > 0 {
+		opts.SetLimit(filter.Limit)
+	}
+
+	cursor, err := p.Collection.Find(ctx, query, opts)
+	if err != nil {
+		return nil, 0, observeErr("find", err)
+	}
+	defer cursor.Close(ctx)
+
+	if err = cursor.All(ctx, &entries); err != nil {
+		return nil, 0, err
+	}
+	return entries, total, nil
+}
+
+// Delete 方法按条件批量删除分享内容，返回被删除的 key 列表，用于记录审计事件
+func (p _Paste) Delete(ctx context.Context, filter PasteFilter) ([]string, error) {
+	// 空条件会匹配全部文档，必须拒绝
+	if filter.IsEmpty() {
+		return nil, errors.New("delete filter must not be empty")
+	}
+
+	// 先查出匹配的 key，再按 key 删除，保证返回的列表与实际删除的文档一致
+	cursor, err := p.Collection.Find(ctx, filter.toBSON(), options.Find().SetProjection(bson.M{"key": 1, "snippets.file_id": 1, "images.storage_type": 1, "images.hash": 1, "images.thumbnail.hash": 1}))
+	if err != nil {
+		return nil, observeErr("find", err)
+	}
+	var matched []struct {
+		Key         string             `bson:"key"`
+		Snippets    []proto.Snippet    `bson:"snippets"`
+		Attachments []proto.Attachment `bson:"images"`
+	}
+	if err = cursor.All(ctx, &matched); err != nil {
+		return nil, err
+	}
+	if len(matched) == 0 {
+		return nil, nil
+	}
+
+	keys := make([]string, 0, len(matched))
+	var (
+		fileIDs     []string
+		attachments []proto.Attachment
+	)
+	for _, m := range matched {
+		keys = append(keys, m.Key)
+		fileIDs = append(fileIDs, snippetFileIDs(m.Snippets)...)
+		attachments = append(attachments, m.Attachments...)
+	}
+	if _, err = p.Collection.DeleteMany(ctx, bson.M{"key": bson.M{"$in": keys}}); err != nil {
+		return nil, observeErr("delete", err)
+	}
+	p.removeSnippetFiles(ctx, fileIDs)
+	p.releaseAttachments(ctx, attachments)
+	return keys, nil
+}
+
+// Exists 方法检查指定 key 的分享内容是否存在，不会触发一次性分享的销毁
+func (p _Paste) Exists(ctx context.Context, key string) (bool, error) {
+	count, err := p.Collection.CountDocuments(ctx, bson.M{"key": key}, options.Count().SetLimit(1))
+	if err != nil {
+		return false, observeErr("count", err)
+	}
+	return count > 0, nil
+}
+
+// Stats 方法统计分享数量以及按存储类型划分的图片用量
+func (p _Paste) Stats(ctx context.Context) (stats PasteStats, err error) {
+	if stats.Pastes, err = p.Collection.EstimatedDocumentCount(ctx); err != nil {
+		return
+	}
+	if stats.Once, err = p.Collection.CountDocuments(ctx, bson.M{"once": true}); err != nil {
+		return
+	}
+	if stats.Protected, err = p.Collection.CountDocuments(ctx, bson.M{"password": bson.M{"$exists": true, "$ne": ""}}); err != nil {
+		return
+	}
+
+	// 展开 images 数组后按存储类型分组，统计数量和字节数
+	pipeline := bson.A{
+		bson.M{"$unwind": "$images"},
+		bson.M{"$group": bson.M{
+			"_id":   "$images.storage_type",
+			"count": bson.M{"$sum": 1},
+			"bytes": bson.M{"$sum": "$images.size"},
+		}},
+		bson.M{"$sort": bson.M{"_id": 1}},
+	}
+	cursor, err := p.Collection.Aggregate(ctx, pipeline)
+	if err != nil {
+		observeErr("aggregate", err)
+		return
+	}
+	defer cursor.Close(ctx)
+
+	err = cursor.All(ctx, &stats.Storage)
+	return
+}
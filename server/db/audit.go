@@ -0,0 +1,184 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"paste.org.cn/paste/server/util"
+)
+
+// 审计事件类型
+const (
+	AuditCreated  = "created"  // 创建分享
+	AuditRead     = "read"     // 读取分享
+	AuditBurned   = "burned"   // 一次性分享被读取后销毁
+	AuditDeleted  = "deleted"  // 管理员删除分享
+	AuditReported = "reported" // 分享被举报
+)
+
+// AuditEvent 表示一条分享生命周期审计事件
+type AuditEvent struct {
+	Type      string    `json:"type" bson:"type"`                         // 事件类型
+	Key       string    `json:"key" bson:"key"`                           // 分享 key
+	ClientIP  string    `json:"client_ip" bson:"client_ip"`               // 客户端 IP
+	User      string    `json:"user,omitempty" bson:"user,omitempty"`     // 认证用户（访问令牌名称），匿名访问为空
+	ReqID     string    `json:"reqid" bson:"reqid"`                       // 请求 ID
+	Detail    string    `json:"detail,omitempty" bson:"detail,omitempty"` // 附加信息，例如举报原因
+	Timestamp time.Time `json:"timestamp" bson:"timestamp"`               // 发生时间
+}
+
+// AuditFilter 定义了审计事件的查询条件，零值字段表示不限制
+type AuditFilter struct {
+	Type     string    // 按事件类型匹配
+	Key      string    // 按分享 key 匹配
+	ClientIP string    // 按客户端 IP 匹配
+	User     string    // 按认证用户匹配
+	From     time.Time // 发生时间起始（包含）
+	To       time.Time // 发生时间结束（不包含）
+	Limit    int64     // 返回数量上限
+	Skip     int64     // 跳过的数量，用于分页
+}
+
+// Audit 接口定义了审计事件的操作，只允许追加和查询，不提供修改和删除
+type Audit interface {
+	Append(ctx context.Context, event AuditEvent) error
+	Find(ctx context.Context, filter AuditFilter) ([]AuditEvent, int64, error)
+	Export(ctx context.Context, filter AuditFilter, w io.Writer) (int64, error)
+}
+
+// _Audit 结构体是 Audit 接口的实现
+type _Audit struct {
+	*mongo.Collection
+}
+
+// NewAudit 使用已建立的 MongoDB 连接创建审计事件存储，需要在 NewPaste 之后调用
+func NewAudit(ctx context.Context, config util.MongoConfig) (Audit, error) {
+	client := GetMongoClient()
+	if client == nil {
+		return nil, errors.New("mongo client is not initialized")
+	}
+
+	audit := _Audit{
+		Collection: client.Database(config.DB).Collection(config.AuditColl),
+	}
+	if err := audit.Init(ctx); err != nil {
+		return nil, err
+	}
+	return audit, nil
+}
+
+// Init 方法用于初始化审计集合的索引
+func (a _Audit) Init(ctx context.Context) error {
+	models := []mongo.IndexModel{
+		{
+			Keys: bson.D{{Key: "key", Value: 1}, {Key: "timestamp", Value: -1}},
+		},
+		{
+			Keys: bson.D{{Key: "timestamp", Value: -1}},
+		},
+	}
+	opts := options.CreateIndexes().SetMaxTime(1 * time.Minute)
+	_, err := a.Indexes().CreateMany(ctx, models, opts)
+	return err
+}
+
+// Append 方法追加一条审计事件
+func (a _Audit) Append(ctx context.Context, event AuditEvent) error {
+	if event.Timestamp.IsZero() {
+		event.Timestamp = time.Now()
+	}
+	_, err := a.Collection.InsertOne(ctx, event)
+	return observeErr("audit_insert", err)
+}
+
+// toBSON 将 AuditFilter 转换为 MongoDB 查询条件
+func (f AuditFilter) toBSON() bson.M {
+	filter := bson.M{}
+	if f.Type != "" {
+		filter["type"] = f.Type
+	}
+	if f.Key != "" {
+		filter["key"] = f.Key
+	}
+	if f.ClientIP != "" {
+		filter["client_ip"] = f.ClientIP
+	}
+	if f.User != "" {
+		filter["user"] = f.User
+	}
+	if !f.From.IsZero() || !f.To.IsZero() {
+		timestamp := bson.M{}
+		if !f.From.IsZero() {
+			timestamp["$gte"] = f.From
+		}
+		if !f.To.IsZero() {
+			timestamp["$lt"] = f.To
+		}
+		filter["timestamp"] = timestamp
+	}
+	return filter
+}
+
+// Find 方法按条件查询审计事件，按发生时间倒序返回，并返回满足条件的总数
+func (a _Audit) Find(ctx context.Context, filter AuditFilter) (events []AuditEvent, total int64, err error) {
+	query := filter.toBSON()
+
+	total, err = a.Collection.CountDocuments(ctx, query)
+	if err != nil {
+		return nil, 0, observeErr("audit_count", err)
+	}
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "timestamp", Value: -1}}).
+		SetSkip(filter.Skip)
+	if filter.Limit > 0 {
+		opts.SetLimit(filter.Limit)
+	}
+
+	cursor, err := a.Collection.Find(ctx, query, opts)
+	if err != nil {
+		return nil, 0, observeErr("audit_find", err)
+	}
+	defer cursor.Close(ctx)
+
+	if err = cursor.All(ctx, &events); err != nil {
+		return nil, 0, err
+	}
+	return events, total, nil
+}
+
+// Export 方法按发生时间正序将满足条件的审计事件以 JSON Lines 格式写入 w，返回写入的数量
+func (a _Audit) Export(ctx context.Context, filter AuditFilter, w io.Writer) (int64, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "timestamp", Value: 1}})
+	if filter.Limit > 0 {
+		opts.SetLimit(filter.Limit)
+	}
+
+	cursor, err := a.Collection.Find(ctx, filter.toBSON(), opts)
+	if err != nil {
+		return 0, observeErr("audit_find", err)
+	}
+	defer cursor.Close(ctx)
+
+	// 逐条解码写出，避免一次性加载全部事件
+	var count int64
+	encoder := json.NewEncoder(w)
+	for cursor.Next(ctx) {
+		var event AuditEvent
+		if err := cursor.Decode(&event); err != nil {
+			return count, err
+		}
+		if err := encoder.Encode(event); err != nil {
+			return count, err
+		}
+		count++
+	}
+	return count, cursor.Err()
+}
@@ -0,0 +1,230 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"paste.org.cn/paste/server/proto"
+)
+
+// BlobEntry 表示按内容哈希去重后的一个图片对象
+type BlobEntry struct {
+	Hash        string    `bson:"_id"`          // 内容的 SHA-256 哈希
+	ObjectKey   string    `bson:"object_key"`   // 对象存储中的唯一标识符，每次新建记录都会生成新的 key
+	ContentType string    `bson:"content_type"` // 文件MIME类型
+	Size        int64     `bson:"size"`         // 文件大小（字节）
+	Refs        int64     `bson:"refs"`         // 引用该对象的图片数量
+	Uploaded    bool      `bson:"uploaded"`     // 对象是否已上传完成
+	CreatedAt   time.Time `bson:"created_at"`   // 创建时间
+	UpdatedAt   time.Time `bson:"updated_at"`   // 最近一次引用计数变化的时间
+}
+
+// Blob 接口定义了去重图片对象的引用计数操作
+type Blob interface {
+	// Acquire 增加一次引用，记录不存在时使用 entry 创建，返回当前记录，调用方需要在 Uploaded 为 false 时上传对象
+	Acquire(ctx context.Context, entry BlobEntry) (BlobEntry, error)
+	// MarkUploaded 标记对象已上传完成
+	MarkUploaded(ctx context.Context, hash string) error
+	// Release 减少引用，hashes 中重复的哈希会减少多次
+	Release(ctx context.Context, hashes ...string) error
+	// Collect 校正引用计数，删除超过 grace 仍没有引用的记录，并对每条被删除的记录调用 remove 删除对象
+	Collect(ctx context.Context, grace time.Duration, remove func(BlobEntry) error) (int64, error)
+}
+
+// 使用去重对象的存储类型，与 storage.StorageTypeCloud 一致
+const blobStorageType = "cloud"
+
+// 没有引用的对象在删除前保留的时间，避免删除上传后尚未写入分享的对象
+const BlobGracePeriod = time.Hour
+
+// _Blob 结构体是 Blob 接口的实现，pastes 用于校正引用计数
+type _Blob struct {
+	blobs  *mongo.Collection
+	pastes *mongo.Collection
+}
+
+// Init 方法用于初始化索引
+func (b _Blob) Init(ctx context.Context) error {
+	opts := options.CreateIndexes().SetMaxTime(1 * time.Minute)
+	if _, err := b.blobs.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "updated_at", Value: 1}},
+	}, opts); err != nil {
+		return err
+	}
+	// 校正引用计数时按哈希反查分享
+	_, err := b.pastes.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "images.hash", Value: 1}}, Options: options.Index().SetSparse(true)},
+		{Keys: bson.D{{Key: "images.thumbnail.hash", Value: 1}}, Options: options.Index().SetSparse(true)},
+	}, opts)
+	return err
+}
+
+// Acquire 方法原子地增加引用计数，记录不存在时创建
+func (b _Blob) Acquire(ctx context.Context, entry BlobEntry) (result BlobEntry, err error) {
+	now := time.Now()
+	update := bson.M{
+		"$inc": bson.M{"refs": 1},
+		"$set": bson.M{"updated_at": now},
+		"$setOnInsert": bson.M{
+			"object_key":   entry.ObjectKey,
+			"content_type": entry.ContentType,
+			"size":         entry.Size,
+			"uploaded":     false,
+			"created_at":   now,
+		},
+	}
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+	err = b.blobs.FindOneAndUpdate(ctx, bson.M{"_id": entry.Hash}, update, opts).Decode(&result)
+	return result, observeErr("blob_acquire", err)
+}
+
+// MarkUploaded 方法标记对象已上传完成
+func (b _Blob) MarkUploaded(ctx context.Context, hash string) error {
+	_, err := b.blobs.UpdateOne(ctx, bson.M{"_id": hash}, bson.M{"$set": bson.M{"uploaded": true}})
+	return observeErr("blob_update", err)
+}
+
+// Release 方法减少引用计数，计数归零的记录由 Collect 在宽限期后删除
+func (b _Blob) Release(ctx context.Context, hashes ...string) error {
+	counts := make(map[string]int64, len(hashes))
+	for _, hash := range hashes {
+		if hash != "" {
+			counts[hash]++
+		}
+	}
+
+	var errs []error
+	for hash, n := range counts {
+		_, err := b.blobs.UpdateOne(ctx, bson.M{"_id": hash}, bson.M{
+			"$inc": bson.M{"refs": -n},
+			"$set": bson.M{"updated_at": time.Now()},
+		})
+		if err != nil {
+			errs = append(errs, observeErr("blob_release", err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Collect 方法校正引用计数并删除没有引用的记录
+// 分享通过过期索引自动删除时不会减少引用计数，这里按分享集合中的实际引用数量校正
+// 所有更新都以 updated_at 未变化为条件，避免覆盖并发的 Acquire
+func (b _Blob) Collect(ctx context.Context, grace time.Duration, remove func(BlobEntry) error) (int64, error) {
+	cutoff := time.Now().Add(-grace)
+
+	cursor, err := b.blobs.Find(ctx, bson.M{"updated_at": bson.M{"$lt": cutoff}})
+	if err != nil {
+		return 0, observeErr("blob_find", err)
+	}
+	defer cursor.Close(ctx)
+
+	var removed int64
+	for cursor.Next(ctx) {
+		var entry BlobEntry
+		if err := cursor.Decode(&entry); err != nil {
+			return removed, err
+		}
+
+		refs, err := b.countRefs(ctx, entry.Hash)
+		if err != nil {
+			return removed, err
+		}
+		if refs > 0 {
+			if refs != entry.Refs {
+				_, err = b.blobs.UpdateOne(ctx, bson.M{"_id": entry.Hash, "updated_at": entry.UpdatedAt}, bson.M{"$set": bson.M{"refs": refs}})
+				if err != nil {
+					return removed, observeErr("blob_update", err)
+				}
+			}
+			continue
+		}
+
+		// 先删除记录再删除对象：删除后新的 Acquire 会创建使用新 ObjectKey 的记录，不会引用即将删除的对象
+		result, err := b.blobs.DeleteOne(ctx, bson.M{"_id": entry.Hash, "updated_at": entry.UpdatedAt})
+		if err != nil {
+			return removed, observeErr("blob_delete", err)
+		}
+		if result.DeletedCount == 0 {
+			continue
+		}
+		if err := remove(entry); err != nil {
+			return removed, err
+		}
+		removed++
+	}
+	return removed, cursor.Err()
+}
+
+// countRefs 统计分享集合中引用指定哈希的图片和缩略图数量
+func (b _Blob) countRefs(ctx context.Context, hash string) (int64, error) {
+	pipeline := bson.A{
+		bson.M{"$match": bson.M{"$or": bson.A{
+			bson.M{"images.hash": hash},
+			bson.M{"images.thumbnail.hash": hash},
+		}}},
+		bson.M{"$unwind": "$images"},
+		bson.M{"$group": bson.M{
+			"_id": nil,
+			"refs": bson.M{"$sum": bson.M{"$add": bson.A{
+				bson.M{"$cond": bson.A{bson.M{"$eq": bson.A{"$images.hash", hash}}, 1, 0}},
+				bson.M{"$cond": bson.A{bson.M{"$eq": bson.A{"$images.thumbnail.hash", hash}}, 1, 0}},
+			}}},
+		}},
+	}
+	cursor, err := b.pastes.Aggregate(ctx, pipeline)
+	if err != nil {
+		return 0, observeErr("aggregate", err)
+	}
+	defer cursor.Close(ctx)
+
+	var result []struct {
+		Refs int64 `bson:"refs"`
+	}
+	if err := cursor.All(ctx, &result); err != nil || len(result) == 0 {
+		return 0, err
+	}
+	return result[0].Refs, nil
+}
+
+// NewBlobObjectKey 生成去重对象在对象存储中的 key，同一哈希每次新建记录都使用不同的 key
+func NewBlobObjectKey(hash, ext string) string {
+	return "blobs/" + hash[:2] + "/" + hash + "-" + primitive.NewObjectID().Hex() + ext
+}
+
+// attachmentHashes 返回附件及缩略图引用的去重对象哈希
+func attachmentHashes(attachments []proto.Attachment) []string {
+	var hashes []string
+	for _, image := range attachments {
+		if image.StorageType != blobStorageType {
+			continue
+		}
+		if image.Hash != "" {
+			hashes = append(hashes, image.Hash)
+		}
+		if image.Thumbnail != nil && image.Thumbnail.Hash != "" {
+			hashes = append(hashes, image.Thumbnail.Hash)
+		}
+	}
+	return hashes
+}
+
+// releaseAttachments 释放已删除分享中附件的引用，失败时由 Collect 校正
+func (p _Paste) releaseAttachments(ctx context.Context, attachments []proto.Attachment) {
+	if hashes := attachmentHashes(attachments); len(hashes) > 0 {
+		if err := p.blob.Release(ctx, hashes...); err != nil {
+			log.Warnf("释放附件引用失败: %+v", err)
+		}
+	}
+}
+
+// NewStreamObjectKey 生成流式上传对象的 key，上传完成前还不知道内容哈希，不按哈希分目录
+func NewStreamObjectKey(ext string) string {
+	return "blobs/stream/" + primitive.NewObjectID().Hex() + ext
+}
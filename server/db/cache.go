@@ -0,0 +1,72 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+
+	"paste.org.cn/paste/server/cache"
+	"paste.org.cn/paste/server/metrics"
+	"paste.org.cn/paste/server/util"
+)
+
+// 分享内容缓存 key 的前缀
+const pasteCachePrefix = "paste:"
+
+// cachedPaste 在 Paste 前增加一层读缓存
+// 只缓存非一次性、无密码的分享，这类分享读取时不需要销毁也不需要校验密码，可以直接返回缓存内容
+type cachedPaste struct {
+	Paste
+	cache cache.Cache
+	ttl   time.Duration
+}
+
+// NewCachedPaste 为 Paste 增加读缓存，ttl 为分享内容的最长缓存时间
+func NewCachedPaste(p Paste, c cache.Cache, ttl time.Duration) Paste {
+	return cachedPaste{Paste: p, cache: c, ttl: ttl}
+}
+
+// Get 方法优先从缓存读取分享内容，未命中时从数据库读取并写入缓存
+func (p cachedPaste) Get(ctx context.Context, key, password string) (PasteEntry, error) {
+	if data, ok := p.cache.Get(ctx, pasteCachePrefix+key); ok {
+		var entry PasteEntry
+		if err := bson.Unmarshal(data, &entry); err == nil && !entry.expired() {
+			metrics.CacheLookup("paste", true)
+			return entry, nil
+		}
+		p.cache.Delete(ctx, pasteCachePrefix+key)
+	}
+	metrics.CacheLookup("paste", false)
+
+	entry, err := p.Paste.Get(ctx, key, password)
+	if err != nil || entry.Once || entry.Password != "" {
+		return entry, err
+	}
+
+	// 缓存时间不超过分享的过期时间
+	ttl := p.ttl
+	if !entry.ExpireAt.IsZero() {
+		ttl = min(ttl, time.Until(entry.ExpireAt))
+	}
+	if data, err := bson.Marshal(entry); err == nil {
+		p.cache.Set(ctx, pasteCachePrefix+key, data, ttl)
+	} else {
+		_, log := util.EnsureWithLogger(ctx)
+		log.Warnf("序列化分享内容失败，跳过缓存: %+v", err)
+	}
+	return entry, nil
+}
+
+// Delete 方法删除分享内容并使对应的缓存失效
+func (p cachedPaste) Delete(ctx context.Context, filter PasteFilter) ([]string, error) {
+	keys, err := p.Paste.Delete(ctx, filter)
+	if len(keys) > 0 {
+		cacheKeys := make([]string, 0, len(keys))
+		for _, key := range keys {
+			cacheKeys = append(cacheKeys, pasteCachePrefix+key)
+		}
+		p.cache.Delete(ctx, cacheKeys...)
+	}
+	return keys, err
+}
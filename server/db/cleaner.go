@@ -0,0 +1,38 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// CleanTask 清理任务，Clean 返回清理的数量
+type CleanTask struct {
+	Name  string // 清理对象的名称，用于日志
+	Clean func(ctx context.Context) (int64, error)
+}
+
+// RunCleaner 按固定间隔依次执行清理任务，清理不再被引用的外部存储数据，ctx 取消时退出
+func RunCleaner(ctx context.Context, interval time.Duration, tasks ...CleanTask) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			for _, task := range tasks {
+				removed, err := task.Clean(ctx)
+				if err != nil {
+					log.Errorf("清理%s失败: %+v", task.Name, err)
+					continue
+				}
+				if removed > 0 {
+					log.Infof("清理%s %d 个", task.Name, removed)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package db
+
+import (
+	"context"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// LangtypeMigration 记录规范化片段语言类型的结果
+type LangtypeMigration struct {
+	Scanned  int64            `json:"scanned"`  // 检查的分享数量
+	Updated  int64            `json:"updated"`  // 修改的分享数量，dry run 时为需要修改的数量
+	Rewrites map[string]int64 `json:"rewrites"` // 按 "旧值 -> 新值" 统计的片段数量
+}
+
+// NormalizeLangtypes 方法使用 normalize 规范化所有分享的片段语言类型，dryRun 为 true 时只统计不修改。
+// 只读取和修改 langtype 字段，不需要解压片段内容；修改的分享同时删除保存的内容哈希，
+// 读取时按新的语言类型重新计算，ETag 随之变化，浏览器和 CDN 不会继续使用旧的响应
+func (p _Paste) NormalizeLangtypes(ctx context.Context, normalize func(string) string, dryRun bool) (result LangtypeMigration, err error) {
+	result.Rewrites = make(map[string]int64)
+	opts := options.Find().SetProjection(bson.M{"snippets.langtype": 1})
+	cursor, err := p.Collection.Find(ctx, bson.M{}, opts)
+	if err != nil {
+		return result, observeErr("find", err)
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var doc struct {
+			ID       interface{} `bson:"_id"`
+			Snippets []struct {
+				Langtype string `bson:"langtype"`
+			} `bson:"snippets"`
+		}
+		if err = cursor.Decode(&doc); err != nil {
+			return result, err
+		}
+		result.Scanned++
+
+		// 按数组下标修改，并要求旧值未变，避免覆盖迁移期间的其他修改
+		filter, set := bson.M{"_id": doc.ID}, bson.M{}
+		for i, snippet := range doc.Snippets {
+			langtype := normalize(snippet.Langtype)
+			if langtype == snippet.Langtype {
+				continue
+			}
+			field := fmt.Sprintf("snippets.%d.langtype", i)
+			filter[field] = snippet.Langtype
+			set[field] = langtype
+			result.Rewrites[fmt.Sprintf("%q -> %q", snippet.Langtype, langtype)]++
+		}
+		if len(set) == 0 {
+			continue
+		}
+		if dryRun {
+			result.Updated++
+			continue
+		}
+		res, err := p.Collection.UpdateOne(ctx, filter, bson.M{"$set": set, "$unset": bson.M{"content_hash": ""}})
+		if err != nil {
+			return result, observeErr("update", err)
+		}
+		result.Updated += res.ModifiedCount
+	}
+	return result, cursor.Err()
+}
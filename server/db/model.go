@@ -1,20 +1,72 @@
 package db
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"time"
 
 	"paste.org.cn/paste/server/proto"
 )
 
 type PasteEntry struct {
-	Key         string            `json:"key" bson:"key"`                                 // 唯一标识
-	Title       string            `json:"title" bson:"title"`                             // 分享标题
-	Description string            `json:"description" bson:"description"`                 // 分享描述
-	Snippets    []proto.Snippet   `json:"snippets" bson:"snippets"`                       // 多段代码内容
-	Images      []proto.ImageFile       `json:"images,omitempty" bson:"images,omitempty"`       // 多张截图分享内容
-	Password    string            `json:"password,omitempty" bson:"password,omitempty"`   // 密码保护
-	ClientIP    string            `json:"client_ip" bson:"client_ip"`                     // 客户端 IP
-	Once        bool              `json:"once" bson:"once,omitempty"`                     // 是否一次性阅读
-	CreatedAt   time.Time         `json:"created_at" bson:"created_at"`                   // 创建时间
-	ExpireAt    time.Time         `json:"expire_at,omitempty" bson:"expire_at,omitempty"` // 过期时间
-}
\ No newline at end of file
+	Key         string             `json:"key" bson:"key"`                                 // 唯一标识
+	Title       string             `json:"title" bson:"title"`                             // 分享标题
+	Description string             `json:"description" bson:"description"`                 // 分享描述
+	Snippets    []proto.Snippet    `json:"snippets" bson:"snippets"`                       // 多段代码内容
+	Attachments []proto.Attachment `json:"attachments,omitempty" bson:"images,omitempty"`  // 截图和其他附件，沿用 images 字段名兼容已有数据
+	Password    string             `json:"password,omitempty" bson:"password,omitempty"`   // 密码保护
+	ClientIP    string             `json:"client_ip" bson:"client_ip"`                     // 客户端 IP
+	Once        bool               `json:"once" bson:"once,omitempty"`                     // 是否一次性阅读
+	CreatedAt   time.Time          `json:"created_at" bson:"created_at"`                   // 创建时间
+	ExpireAt    time.Time          `json:"expire_at,omitempty" bson:"expire_at,omitempty"` // 过期时间
+	ContentHash string             `json:"-" bson:"content_hash,omitempty"`                // 内容哈希，用于生成 ETag
+}
+
+// Hash 计算分享内容的哈希，只包含会返回给客户端的片段和附件
+// 旧数据没有保存 ContentHash 时，可以调用该方法即时计算
+func (e PasteEntry) Hash() string {
+	h := sha256.New()
+	// 片段和图片都是普通结构体，json 编码结果是确定的
+	_ = json.NewEncoder(h).Encode(e.Snippets)
+	for _, image := range e.Attachments {
+		_ = json.NewEncoder(h).Encode([]any{image.StorageType, image.Filename, image.ContentType, image.Size, image.ObjectKey})
+		h.Write([]byte(image.Base64Content))
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// expired 判断分享内容是否已过期
+func (e PasteEntry) expired() bool {
+	return !e.ExpireAt.IsZero() && time.Now().After(e.ExpireAt)
+}
+
+// PasteFilter 定义了管理接口查询/删除分享内容的条件，零值字段表示不限制
+type PasteFilter struct {
+	Keys     []string  // 按 key 精确匹配
+	ClientIP string    // 按客户端 IP 精确匹配
+	From     time.Time // 创建时间起始（包含）
+	To       time.Time // 创建时间结束（不包含）
+	Limit    int64     // 返回数量上限
+	Skip     int64     // 跳过的数量，用于分页
+}
+
+// IsEmpty 判断过滤条件是否为空
+func (f PasteFilter) IsEmpty() bool {
+	return len(f.Keys) == 0 && f.ClientIP == "" && f.From.IsZero() && f.To.IsZero()
+}
+
+// StorageStat 表示某种存储类型的图片用量
+type StorageStat struct {
+	StorageType string `bson:"_id"`   // 存储类型
+	Count       int64  `bson:"count"` // 图片数量
+	Bytes       int64  `bson:"bytes"` // 图片总大小（字节）
+}
+
+// PasteStats 表示分享内容的整体统计信息
+type PasteStats struct {
+	Pastes    int64         // 分享总数
+	Once      int64         // 一次性分享数量
+	Protected int64         // 设置了密码的分享数量
+	Storage   []StorageStat // 按存储类型统计的图片用量
+}
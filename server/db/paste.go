@@ -1,18 +1,23 @@
 package db
+
 import (
 	"context"
 	"errors"
+	"fmt"
+	"slices"
 	"sync"
 	"time"
 
-	"github.com/google/uuid"
-	"github.com/spf13/viper"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.opentelemetry.io/otel/attribute"
 	"golang.org/x/crypto/bcrypt"
 
+	"paste.org.cn/paste/server/metrics"
 	"paste.org.cn/paste/server/proto"
+	"paste.org.cn/paste/server/tracing"
+	"paste.org.cn/paste/server/util"
 )
 
 // 保存全局MongoDB客户端实例
@@ -25,19 +30,23 @@ var (
 type Paste interface {
 	Set(ctx context.Context, entry PasteEntry) (string, error)
 	Get(ctx context.Context, key, password string) (PasteEntry, error)
+	Attachment(ctx context.Context, key, password string, index int) (proto.Attachment, error)
+	Preview(ctx context.Context, key string) (PasteEntry, error)
+	Find(ctx context.Context, filter PasteFilter) ([]PasteEntry, int64, error)
+	Delete(ctx context.Context, filter PasteFilter) ([]string, error)
+	Exists(ctx context.Context, key string) (bool, error)
+	Stats(ctx context.Context) (PasteStats, error)
+	Clean(ctx context.Context) (int64, error)
+	NormalizeLangtypes(ctx context.Context, normalize func(string) string, dryRun bool) (LangtypeMigration, error)
+	Blobs() Blob
 	GetCollection() *mongo.Collection
 }
 
 // _Paste 结构体是 Paste 接口的实现，内嵌了 mongo.Collection，用于操作 MongoDB 的集合
 type _Paste struct {
 	*mongo.Collection
-}
-
-// 存储 MogoDB 的连接配置
-type MongoConfig struct {
-	Host string // 连接地址
-	DB   string // 数据库名
-	Coll string // 集合名
+	snippet util.SnippetConfig // 片段的压缩和转存配置
+	blob    _Blob              // 去重图片对象的引用计数
 }
 
 // GetMongoClient 返回全局MongoDB客户端实例
@@ -47,15 +56,9 @@ func GetMongoClient() *mongo.Client {
 	return mongoClient
 }
 
-func NewPaste(ctx context.Context, viper_ *viper.Viper) (Paste, error) {
-	var config MongoConfig
-	// 将提取出来的viper实例映射到config
-	if err := viper_.Unmarshal(&config); err != nil {
-		return nil, err
-	}
-
+func NewPaste(ctx context.Context, config util.PasteConfig) (Paste, error) {
 	// 连接 mogoDB
-	client, err := mongo.Connect(ctx, options.Client().ApplyURI(config.Host))
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(config.Mgo.Host))
 	if err != nil {
 		return nil, err
 	}
@@ -66,13 +69,19 @@ func NewPaste(ctx context.Context, viper_ *viper.Viper) (Paste, error) {
 	clientMutex.Unlock()
 
 	// 创建 _Paste 实例，并传入 MongoDB 的 Collection
+	database := client.Database(config.Mgo.DB)
 	paste := _Paste{
-		Collection: client.Database(config.DB).Collection(config.Coll),
+		Collection: database.Collection(config.Mgo.Coll),
+		snippet:    config.Snippet,
 	}
+	paste.blob = _Blob{blobs: database.Collection(config.Mgo.BlobColl), pastes: paste.Collection}
 	// 初始化 Paste 实例，例如创建索引
 	if err := paste.Init(ctx); err != nil {
 		return nil, err // 如果初始化失败，则返回错误
 	}
+	if err := paste.blob.Init(ctx); err != nil {
+		return nil, err
+	}
 	return paste, nil // 返回创建成功的 Paste 实例
 }
 
@@ -97,6 +106,11 @@ func (p _Paste) Init(ctx context.Context) error {
 			Keys:    bson.D{{Key: "expire_at", Value: 1}},
 			Options: options.Index().SetExpireAfterSeconds(0), // 设置过期时间为到达 expire_at 字段立即过期
 		},
+		{
+			// 清理 GridFS 中无引用的片段文件时按文件 ID 反查分享
+			Keys:    bson.D{{Key: "snippets.file_id", Value: 1}},
+			Options: options.Index().SetSparse(true),
+		},
 	}
 
 	// 设置创建索引的选项，例如最大执行时间
@@ -106,6 +120,11 @@ func (p _Paste) Init(ctx context.Context) error {
 	return err // 返回创建索引过程中发生的错误
 }
 
+// Blobs 返回去重图片对象的引用计数存储
+func (p _Paste) Blobs() Blob {
+	return p.blob
+}
+
 // GetCollection 返回 MongoDB 的集合
 func (p _Paste) GetCollection() *mongo.Collection {
 	return p.Collection
@@ -113,20 +132,55 @@ func (p _Paste) GetCollection() *mongo.Collection {
 
 // Set 方法将新的 PasteEntry 存储到 MongoDB 中，并返回生成的唯一键
 func (p _Paste) Set(ctx context.Context, entry PasteEntry) (key string, err error) {
-	// 生成一个更长的随机键，减少碰撞概率
-	entry.Key = uuid.NewString()[:16] //生成长度为16的随机字符串
+	ctx, span := tracing.Start(ctx, "db.Paste.Set", p.spanAttrs()...)
+	defer func() {
+		span.SetAttributes(attribute.String("paste.key", key))
+		tracing.End(span, err)
+	}()
+
+	// 分享内容不可修改，创建时计算一次内容哈希
+	entry.ContentHash = entry.Hash()
 
-	for {
-		// 尝试将 entry 插入到集合中
+	// 压缩较大的片段，复制一份避免修改调用方的数据
+	entry.Snippets = slices.Clone(entry.Snippets)
+	if err = p.encodeSnippets(ctx, entry.Snippets, p.snippet); err != nil {
+		p.removeSnippetFiles(ctx, snippetFileIDs(entry.Snippets))
+		return
+	}
+
+	// 调用方提供 key 时只尝试一次，否则按 paste.key 配置生成随机 key，重复时重新生成，最多尝试 max_retries 次
+	custom := entry.Key != ""
+	maxRetries := util.GetConfig().Paste.Key.MaxRetries
+	for attempt := 1; ; attempt++ {
+		if !custom {
+			entry.Key = util.NewKey()
+		}
 		_, err = p.Collection.InsertOne(ctx, entry)
-		if mongo.IsDuplicateKeyError(err) {
-			// 如果遇到键重复错误，重新生成键并继续尝试插入
-			entry.Key = uuid.NewString()[:16]
-			continue
+		if !mongo.IsDuplicateKeyError(err) {
+			observeErr("insert", err)
+			break
+		}
+		if custom {
+			err = errors.New(proto.ErrKeyExists)
+			break
+		}
+		if attempt >= maxRetries {
+			observeErr("insert", err)
+			err = fmt.Errorf("failed to generate a unique key after %d attempts: %w", attempt, err)
+			break
 		}
-		// 如果没有发生重复键错误，跳出循环
-		break
 	}
+	if err != nil {
+		p.removeSnippetFiles(ctx, snippetFileIDs(entry.Snippets))
+		return
+	}
+
+	// 记录创建指标
+	langtypes := make([]string, 0, len(entry.Snippets))
+	for _, snippet := range entry.Snippets {
+		langtypes = append(langtypes, snippet.Langtype)
+	}
+	metrics.PasteCreated(entry.Once, entry.Password != "", langtypes)
 
 	// 返回生成的键
 	key = entry.Key
@@ -135,6 +189,12 @@ func (p _Paste) Set(ctx context.Context, entry PasteEntry) (key string, err erro
 
 // Get 方法根据提供的键和密码检索相应的 PasteEntry
 func (p _Paste) Get(ctx context.Context, key, password string) (entry PasteEntry, err error) {
+	ctx, span := tracing.Start(ctx, "db.Paste.Get", append(p.spanAttrs(), attribute.String("paste.key", key))...)
+	defer func() {
+		span.SetAttributes(attribute.Bool("paste.once", entry.Once))
+		tracing.End(span, err)
+	}()
+
 	// 创建一个查询条件
 	filter := bson.M{"key": key}
 
@@ -143,28 +203,90 @@ func (p _Paste) Get(ctx context.Context, key, password string) (entry PasteEntry
 		// 使用 FindOneAndDelete 原子地获取并删除文档
 		err = p.Collection.FindOneAndDelete(ctx, filter).Decode(&entry)
 		if err != nil {
-			return entry, err
+			return entry, observeErr("find_and_delete", err)
 		}
+		metrics.OnceBurns.Inc()
 	} else {
 		// 对于非一次性文档，使用普通的 FindOne
 		err = p.Collection.FindOne(ctx, filter).Decode(&entry)
 		if err != nil {
-			return entry, err
+			return entry, observeErr("find", err)
 		}
 	}
 
+	// 一次性分享已经删除，返回前同时删除转存到 GridFS 的片段文件，并释放附件引用
+	if entry.Once {
+		defer p.removeSnippetFiles(ctx, snippetFileIDs(entry.Snippets))
+		defer p.releaseAttachments(ctx, entry.Attachments)
+	}
+
 	// 如果 entry 设置了密码，验证提供的密码是否匹配
-	if entry.Password != "" && bcrypt.CompareHashAndPassword([]byte(entry.Password), []byte(password)) != nil {
-		err = errors.New(proto.ErrWrongPassword) // 密码错误
-		return
+	if entry.Password != "" {
+		_, bcryptSpan := tracing.Start(ctx, "bcrypt.CompareHashAndPassword")
+		mismatch := bcrypt.CompareHashAndPassword([]byte(entry.Password), []byte(password))
+		bcryptSpan.End()
+		if mismatch != nil {
+			err = errors.New(proto.ErrWrongPassword) // 密码错误
+			return
+		}
 	}
 
 	// 检查 entry 是否已过期
-	if !entry.ExpireAt.IsZero() && time.Now().After(entry.ExpireAt) {
+	if entry.expired() {
 		err = errors.New(proto.ErrContentExpired) // 内容已过期
 		return
 	}
 
+	// 还原压缩或转存的片段
+	err = p.decodeSnippets(ctx, entry.Snippets)
+	return
+}
+
+// Attachment 方法返回分享中的第 index 个附件，不读取片段，也不会触发一次性分享的销毁
+// 一次性分享的附件只能随分享内容一起获取，与不存在的分享一样返回 mongo.ErrNoDocuments
+func (p _Paste) Attachment(ctx context.Context, key, password string, index int) (proto.Attachment, error) {
+	var entry PasteEntry
+	opts := options.FindOne().SetProjection(bson.M{"images": 1, "password": 1, "once": 1, "expire_at": 1})
+	err := p.Collection.FindOne(ctx, bson.M{"key": key, "once": bson.M{"$ne": true}}, opts).Decode(&entry)
+	if err != nil {
+		return proto.Attachment{}, observeErr("find", err)
+	}
+
+	if entry.Password != "" {
+		_, bcryptSpan := tracing.Start(ctx, "bcrypt.CompareHashAndPassword")
+		mismatch := bcrypt.CompareHashAndPassword([]byte(entry.Password), []byte(password))
+		bcryptSpan.End()
+		if mismatch != nil {
+			return proto.Attachment{}, errors.New(proto.ErrWrongPassword)
+		}
+	}
+	if entry.expired() {
+		return proto.Attachment{}, errors.New(proto.ErrContentExpired)
+	}
+	if index < 0 || index >= len(entry.Attachments) {
+		return proto.Attachment{}, mongo.ErrNoDocuments
+	}
+	return entry.Attachments[index], nil
+}
+
+// Preview 方法返回用于链接预览的分享内容，不校验密码，也不会触发一次性分享的销毁。
+// 一次性分享和有密码的分享只返回 once、password 等元数据，不读取标题、片段和附件
+func (p _Paste) Preview(ctx context.Context, key string) (entry PasteEntry, err error) {
+	opts := options.FindOne().SetProjection(bson.M{"key": 1, "once": 1, "password": 1, "expire_at": 1, "created_at": 1})
+	if err = p.Collection.FindOne(ctx, bson.M{"key": key}, opts).Decode(&entry); err != nil {
+		return entry, observeErr("find", err)
+	}
+	if entry.expired() {
+		return entry, errors.New(proto.ErrContentExpired)
+	}
+	if entry.Once || entry.Password != "" {
+		return entry, nil
+	}
+
+	if err = p.Collection.FindOne(ctx, bson.M{"key": key}).Decode(&entry); err != nil {
+		return entry, observeErr("find", err)
+	}
+	err = p.decodeSnippets(ctx, entry.Snippets)
 	return
 }
 
@@ -175,7 +297,23 @@ func (p _Paste) isOnceDocument(ctx context.Context, key string) (bool, error) {
 	}
 	err := p.Collection.FindOne(ctx, bson.M{"key": key}).Decode(&result)
 	if err != nil {
-		return false, err
+		return false, observeErr("find", err)
 	}
 	return result.Once, nil
 }
+
+// spanAttrs 返回 MongoDB 操作 span 的公共属性
+func (p _Paste) spanAttrs() []attribute.KeyValue {
+	return []attribute.KeyValue{
+		attribute.String("db.system", "mongodb"),
+		attribute.String("db.collection.name", p.Collection.Name()),
+	}
+}
+
+// observeErr 记录 MongoDB 错误指标，文档不存在不视为错误，返回原错误
+func observeErr(operation string, err error) error {
+	if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+		metrics.MongoErrors.WithLabelValues(operation).Inc()
+	}
+	return err
+}
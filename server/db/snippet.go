@@ -0,0 +1,216 @@
+package db
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/gridfs"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"paste.org.cn/paste/server/proto"
+	"paste.org.cn/paste/server/util"
+)
+
+// 片段压缩算法
+const (
+	EncodingNone = "none"
+	EncodingZstd = "zstd"
+	EncodingGzip = "gzip"
+)
+
+// 清理 GridFS 中无引用的片段文件时的宽限期，避免误删正在写入的分享引用的文件
+const orphanGracePeriod = time.Hour
+
+var (
+	// zstd 的编码器和解码器可以并发使用，全局复用
+	zstdEncoder, _ = zstd.NewWriter(nil)
+	zstdDecoder, _ = zstd.NewReader(nil)
+)
+
+// snippetBucket 返回存放大片段的 GridFS bucket
+// gridfs.Bucket 内部复用读写缓冲区，不能并发使用，每次操作都需要新建
+func (p _Paste) snippetBucket(ctx context.Context) (*gridfs.Bucket, error) {
+	bucket, err := gridfs.NewBucket(p.Database(), options.GridFSBucket().SetName(p.Collection.Name()+"_snippets"))
+	if err != nil {
+		return nil, err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = bucket.SetReadDeadline(deadline)
+		_ = bucket.SetWriteDeadline(deadline)
+	}
+	return bucket, nil
+}
+
+// encodeSnippets 在写入前压缩较大的片段，压缩后仍然过大的片段转存到 GridFS，避免超出 MongoDB 单个文档 16MB 的限制
+func (p _Paste) encodeSnippets(ctx context.Context, snippets []proto.Snippet, config util.SnippetConfig) error {
+	for i := range snippets {
+		snippet := &snippets[i]
+		if config.Compression == EncodingNone || len(snippet.Content) < config.CompressMinSize<<10 {
+			continue
+		}
+
+		data, err := compress(config.Compression, []byte(snippet.Content))
+		if err != nil {
+			return fmt.Errorf("压缩片段失败: %w", err)
+		}
+		snippet.Encoding, snippet.Data, snippet.Content = config.Compression, data, ""
+
+		if len(data) < config.SpillSize<<10 {
+			continue
+		}
+		bucket, err := p.snippetBucket(ctx)
+		if err != nil {
+			return err
+		}
+		fileID, err := bucket.UploadFromStream(snippet.Langtype, bytes.NewReader(data))
+		if err != nil {
+			return fmt.Errorf("片段转存 GridFS 失败: %w", observeErr("gridfs_upload", err))
+		}
+		snippet.FileID, snippet.Data = fileID.Hex(), nil
+	}
+	return nil
+}
+
+// decodeSnippets 将压缩或转存的片段还原为 Content
+func (p _Paste) decodeSnippets(ctx context.Context, snippets []proto.Snippet) error {
+	for i := range snippets {
+		snippet := &snippets[i]
+		if snippet.FileID != "" {
+			fileID, err := primitive.ObjectIDFromHex(snippet.FileID)
+			if err != nil {
+				return err
+			}
+			bucket, err := p.snippetBucket(ctx)
+			if err != nil {
+				return err
+			}
+			var buf bytes.Buffer
+			if _, err = bucket.DownloadToStream(fileID, &buf); err != nil {
+				return fmt.Errorf("从 GridFS 读取片段失败: %w", observeErr("gridfs_download", err))
+			}
+			snippet.Data = buf.Bytes()
+		}
+		if snippet.Encoding != "" {
+			content, err := decompress(snippet.Encoding, snippet.Data)
+			if err != nil {
+				return fmt.Errorf("解压片段失败: %w", err)
+			}
+			snippet.Content = string(content)
+		}
+		snippet.Encoding, snippet.Data, snippet.FileID = "", nil, ""
+	}
+	return nil
+}
+
+// snippetFileIDs 返回片段转存到 GridFS 的文件 ID
+func snippetFileIDs(snippets []proto.Snippet) []string {
+	var ids []string
+	for _, snippet := range snippets {
+		if snippet.FileID != "" {
+			ids = append(ids, snippet.FileID)
+		}
+	}
+	return ids
+}
+
+// removeSnippetFiles 删除 GridFS 中的片段文件，失败只记录日志，遗留的文件由 Clean 清理
+func (p _Paste) removeSnippetFiles(ctx context.Context, ids []string) {
+	if len(ids) == 0 {
+		return
+	}
+	_, log := util.EnsureWithLogger(ctx)
+	bucket, err := p.snippetBucket(ctx)
+	if err != nil {
+		log.Warnf("删除 GridFS 片段文件失败: %+v", err)
+		return
+	}
+	for _, id := range ids {
+		fileID, err := primitive.ObjectIDFromHex(id)
+		if err == nil {
+			err = bucket.Delete(fileID)
+		}
+		if err != nil && err != gridfs.ErrFileNotFound {
+			log.Warnf("删除 GridFS 片段文件 %s 失败: %+v", id, observeErr("gridfs_delete", err))
+		}
+	}
+}
+
+// Clean 方法清理 GridFS 中不再被任何分享引用的片段文件，例如通过过期索引自动删除的分享，返回清理的数量
+func (p _Paste) Clean(ctx context.Context) (int64, error) {
+	bucket, err := p.snippetBucket(ctx)
+	if err != nil {
+		return 0, err
+	}
+	cursor, err := bucket.Find(bson.M{"uploadDate": bson.M{"$lt": time.Now().Add(-orphanGracePeriod)}},
+		options.GridFSFind().SetBatchSize(100))
+	if err != nil {
+		return 0, observeErr("gridfs_find", err)
+	}
+	defer cursor.Close(ctx)
+
+	var removed int64
+	for cursor.Next(ctx) {
+		var file struct {
+			ID primitive.ObjectID `bson:"_id"`
+		}
+		if err := cursor.Decode(&file); err != nil {
+			return removed, err
+		}
+		count, err := p.Collection.CountDocuments(ctx, bson.M{"snippets.file_id": file.ID.Hex()}, options.Count().SetLimit(1))
+		if err != nil {
+			return removed, observeErr("count", err)
+		}
+		if count > 0 {
+			continue
+		}
+		if err := bucket.Delete(file.ID); err != nil && err != gridfs.ErrFileNotFound {
+			return removed, observeErr("gridfs_delete", err)
+		}
+		removed++
+	}
+	return removed, cursor.Err()
+}
+
+// compress 使用指定算法压缩数据
+func compress(encoding string, data []byte) ([]byte, error) {
+	switch encoding {
+	case EncodingZstd:
+		return zstdEncoder.EncodeAll(data, nil), nil
+	case EncodingGzip:
+		var buf bytes.Buffer
+		w := gzip.NewWriter(&buf)
+		if _, err := w.Write(data); err != nil {
+			return nil, err
+		}
+		if err := w.Close(); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	default:
+		return nil, fmt.Errorf("不支持的压缩算法: %s", encoding)
+	}
+}
+
+// decompress 使用指定算法解压数据
+func decompress(encoding string, data []byte) ([]byte, error) {
+	switch encoding {
+	case EncodingZstd:
+		return zstdDecoder.DecodeAll(data, nil)
+	case EncodingGzip:
+		r, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		defer r.Close()
+		return io.ReadAll(r)
+	default:
+		return nil, fmt.Errorf("不支持的压缩算法: %s", encoding)
+	}
+}
@@ -0,0 +1,213 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/gridfs"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"paste.org.cn/paste/server/util"
+)
+
+// UploadPart 表示可续传上传中一次 PATCH 写入的数据
+type UploadPart struct {
+	Offset    int64  `bson:"offset"`               // 在文件中的起始位置
+	Size      int64  `bson:"size"`                 // 数据大小（字节）
+	ObjectKey string `bson:"object_key,omitempty"` // 云存储中的对象
+	FileID    string `bson:"file_id,omitempty"`    // GridFS 文件 ID，base64 存储时使用
+}
+
+// UploadEntry 表示一个 tus 可续传上传
+type UploadEntry struct {
+	ID        string            `bson:"_id"`                // 上传 ID
+	Length    int64             `bson:"length"`             // 文件总大小（字节）
+	Offset    int64             `bson:"offset"`             // 已接收的字节数
+	Metadata  map[string]string `bson:"metadata,omitempty"` // 客户端提供的元数据，例如 filename、filetype
+	Parts     []UploadPart      `bson:"parts"`              // 按偏移量顺序排列的数据块
+	ClientIP  string            `bson:"client_ip"`          // 客户端 IP
+	CreatedAt time.Time         `bson:"created_at"`         // 创建时间
+	ExpireAt  time.Time         `bson:"expire_at"`          // 过期时间，过期后未完成或未被引用的上传会被清理
+}
+
+// Completed 判断上传是否已完成
+func (e UploadEntry) Completed() bool {
+	return e.Offset == e.Length
+}
+
+// Upload 接口定义了可续传上传的操作
+type Upload interface {
+	// Create 创建上传记录
+	Create(ctx context.Context, entry UploadEntry) error
+	// Get 返回未过期的上传记录，不存在时返回 mongo.ErrNoDocuments
+	Get(ctx context.Context, id string) (UploadEntry, error)
+	// Append 在当前偏移量等于 part.Offset 时追加数据块，偏移量不一致时返回 false
+	Append(ctx context.Context, id string, part UploadPart) (bool, error)
+	// Claim 原子地取出并删除已完成且未过期的上传，用于创建分享，同一上传只能被引用一次
+	Claim(ctx context.Context, id string) (UploadEntry, error)
+	// Delete 删除上传记录并返回，用于客户端终止上传
+	Delete(ctx context.Context, id string) (UploadEntry, error)
+	// Expire 逐个删除已过期的上传记录，并调用 remove 删除数据块，返回清理的数量
+	Expire(ctx context.Context, remove func(UploadEntry)) (int64, error)
+	// PutPart 将 r 中的数据块写入 GridFS，返回文件 ID
+	PutPart(ctx context.Context, r io.Reader) (string, error)
+	// ReadPart 从 GridFS 读取数据块
+	ReadPart(ctx context.Context, fileID string, w io.Writer) error
+	// RemovePart 删除 GridFS 中的数据块
+	RemovePart(ctx context.Context, fileID string) error
+}
+
+// _Upload 结构体是 Upload 接口的实现
+type _Upload struct {
+	*mongo.Collection
+}
+
+// NewUpload 使用已建立的 MongoDB 连接创建可续传上传存储，需要在 NewPaste 之后调用
+func NewUpload(ctx context.Context, config util.MongoConfig) (Upload, error) {
+	client := GetMongoClient()
+	if client == nil {
+		return nil, errors.New("mongo client is not initialized")
+	}
+
+	upload := _Upload{
+		Collection: client.Database(config.DB).Collection(config.UploadColl),
+	}
+	if err := upload.Init(ctx); err != nil {
+		return nil, err
+	}
+	return upload, nil
+}
+
+// Init 方法用于初始化索引，过期的上传需要同时删除数据块，不使用过期索引自动删除
+func (u _Upload) Init(ctx context.Context) error {
+	opts := options.CreateIndexes().SetMaxTime(1 * time.Minute)
+	_, err := u.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "expire_at", Value: 1}},
+	}, opts)
+	return err
+}
+
+// partBucket 返回存放数据块的 GridFS bucket，每次操作都需要新建
+func (u _Upload) partBucket(ctx context.Context) (*gridfs.Bucket, error) {
+	bucket, err := gridfs.NewBucket(u.Database(), options.GridFSBucket().SetName(u.Collection.Name()+"_parts"))
+	if err != nil {
+		return nil, err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = bucket.SetReadDeadline(deadline)
+		_ = bucket.SetWriteDeadline(deadline)
+	}
+	return bucket, nil
+}
+
+// Create 方法创建上传记录
+func (u _Upload) Create(ctx context.Context, entry UploadEntry) error {
+	if entry.Parts == nil {
+		entry.Parts = []UploadPart{}
+	}
+	_, err := u.Collection.InsertOne(ctx, entry)
+	return observeErr("upload_insert", err)
+}
+
+// Get 方法返回未过期的上传记录
+func (u _Upload) Get(ctx context.Context, id string) (entry UploadEntry, err error) {
+	err = u.Collection.FindOne(ctx, bson.M{"_id": id, "expire_at": bson.M{"$gt": time.Now()}}).Decode(&entry)
+	return entry, observeErr("upload_find", err)
+}
+
+// Append 方法以偏移量为条件追加数据块，保证并发的 PATCH 只有一个成功
+func (u _Upload) Append(ctx context.Context, id string, part UploadPart) (bool, error) {
+	result, err := u.Collection.UpdateOne(ctx, bson.M{
+		"_id":       id,
+		"offset":    part.Offset,
+		"expire_at": bson.M{"$gt": time.Now()},
+	}, bson.M{
+		"$inc":  bson.M{"offset": part.Size},
+		"$push": bson.M{"parts": part},
+	})
+	if err != nil {
+		return false, observeErr("upload_update", err)
+	}
+	return result.ModifiedCount == 1, nil
+}
+
+// Claim 方法取出并删除已完成且未过期的上传
+func (u _Upload) Claim(ctx context.Context, id string) (entry UploadEntry, err error) {
+	err = u.Collection.FindOneAndDelete(ctx, bson.M{
+		"_id":       id,
+		"expire_at": bson.M{"$gt": time.Now()},
+		"$expr":     bson.M{"$eq": bson.A{"$offset", "$length"}},
+	}).Decode(&entry)
+	return entry, observeErr("upload_claim", err)
+}
+
+// Delete 方法删除上传记录
+func (u _Upload) Delete(ctx context.Context, id string) (entry UploadEntry, err error) {
+	err = u.Collection.FindOneAndDelete(ctx, bson.M{"_id": id}).Decode(&entry)
+	return entry, observeErr("upload_delete", err)
+}
+
+// Expire 方法逐个删除已过期的上传记录
+func (u _Upload) Expire(ctx context.Context, remove func(UploadEntry)) (int64, error) {
+	var removed int64
+	for {
+		var entry UploadEntry
+		err := u.Collection.FindOneAndDelete(ctx, bson.M{"expire_at": bson.M{"$lte": time.Now()}}).Decode(&entry)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return removed, nil
+		}
+		if err != nil {
+			return removed, observeErr("upload_delete", err)
+		}
+		remove(entry)
+		removed++
+	}
+}
+
+// PutPart 方法边读取边将数据块写入 GridFS
+func (u _Upload) PutPart(ctx context.Context, r io.Reader) (string, error) {
+	bucket, err := u.partBucket(ctx)
+	if err != nil {
+		return "", err
+	}
+	fileID, err := bucket.UploadFromStream("part", r)
+	if err != nil {
+		return "", observeErr("gridfs_upload", err)
+	}
+	return fileID.Hex(), nil
+}
+
+// ReadPart 方法从 GridFS 读取数据块
+func (u _Upload) ReadPart(ctx context.Context, fileID string, w io.Writer) error {
+	id, err := primitive.ObjectIDFromHex(fileID)
+	if err != nil {
+		return err
+	}
+	bucket, err := u.partBucket(ctx)
+	if err != nil {
+		return err
+	}
+	_, err = bucket.DownloadToStream(id, w)
+	return observeErr("gridfs_download", err)
+}
+
+// RemovePart 方法删除 GridFS 中的数据块，文件不存在时不返回错误
+func (u _Upload) RemovePart(ctx context.Context, fileID string) error {
+	id, err := primitive.ObjectIDFromHex(fileID)
+	if err != nil {
+		return err
+	}
+	bucket, err := u.partBucket(ctx)
+	if err != nil {
+		return err
+	}
+	if err = bucket.Delete(id); err != nil && err != gridfs.ErrFileNotFound {
+		return observeErr("gridfs_delete", err)
+	}
+	return nil
+}
@@ -0,0 +1,189 @@
+package imaging
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif" // 注册 GIF 解码器
+	"image/jpeg"
+	"image/png"
+	"net/http"
+
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp" // 注册 WebP 解码器
+)
+
+// 支持处理的图片格式，取值与 image.DecodeConfig 返回的格式名一致
+const (
+	FormatPNG  = "png"
+	FormatJPEG = "jpeg"
+	FormatGIF  = "gif"
+	FormatWebP = "webp"
+)
+
+// 允许上传的图片格式，其他格式（包括可能携带脚本的 SVG）一律拒绝
+var allowedFormats = map[string]bool{
+	FormatPNG:  true,
+	FormatJPEG: true,
+	FormatGIF:  true,
+	FormatWebP: true,
+}
+
+// 允许解码的最大像素数，防止解压炸弹耗尽内存
+const maxPixels = 50_000_000
+
+// 重新编码时使用的 JPEG 质量
+const (
+	jpegQuality      = 90
+	thumbnailQuality = 80
+)
+
+var (
+	ErrUnsupportedFormat = errors.New("unsupported image format")
+	ErrCorrupted         = errors.New("image data cannot be decoded")
+	ErrTooManyPixels     = errors.New("image dimensions are too large")
+)
+
+// Options 图片处理选项
+type Options struct {
+	StripMetadata   bool  // 是否移除 EXIF 等元数据，JPEG 会同时按 EXIF 方向旋转
+	ThumbnailSize   int   // 缩略图最长边的像素数，0 表示不生成缩略图
+	ReencodePNGSize int64 // 超过该字节数的 PNG 尝试以最高压缩率重新编码，0 表示不处理
+}
+
+// Variant 表示处理后的一个图片版本
+type Variant struct {
+	Data        []byte // 图片内容
+	ContentType string // MIME 类型
+	Width       int    // 宽度（像素）
+	Height      int    // 高度（像素）
+}
+
+// Result 图片处理结果
+type Result struct {
+	Format    string   // 检测到的图片格式
+	Original  Variant  // 处理后的原图
+	Thumbnail *Variant // 缩略图，原图不超过缩略图尺寸时为空
+}
+
+// Detect 根据文件头的魔数识别图片格式，不信任客户端提供的 Content-Type 和文件名
+// 不在允许列表中的格式返回 ErrUnsupportedFormat
+func Detect(data []byte) (string, error) {
+	var format string
+	switch {
+	case bytes.HasPrefix(data, pngSignature):
+		format = FormatPNG
+	case bytes.HasPrefix(data, []byte{0xFF, 0xD8, 0xFF}):
+		format = FormatJPEG
+	case bytes.HasPrefix(data, []byte("GIF87a")), bytes.HasPrefix(data, []byte("GIF89a")):
+		format = FormatGIF
+	case len(data) >= 12 && string(data[0:4]) == "RIFF" && string(data[8:12]) == "WEBP":
+		format = FormatWebP
+	}
+	if !allowedFormats[format] {
+		return "", fmt.Errorf("%w: %s", ErrUnsupportedFormat, http.DetectContentType(data))
+	}
+	return format, nil
+}
+
+// Process 识别并完整解码图片，移除元数据，生成缩略图，并按需重新压缩较大的 PNG
+// 不允许的格式返回 ErrUnsupportedFormat，无法解码的图片返回 ErrCorrupted
+func Process(data []byte, opts Options) (*Result, error) {
+	format, err := Detect(data)
+	if err != nil {
+		return nil, err
+	}
+	config, decoded, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil || decoded != format {
+		return nil, fmt.Errorf("%w: %v", ErrCorrupted, err)
+	}
+	if config.Width*config.Height > maxPixels {
+		return nil, ErrTooManyPixels
+	}
+
+	result := &Result{
+		Format: format,
+		Original: Variant{
+			Data:        data,
+			ContentType: "image/" + format,
+			Width:       config.Width,
+			Height:      config.Height,
+		},
+	}
+
+	// 完整解码一次，确认图片数据有效，后续处理复用解码结果
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrCorrupted, err)
+	}
+
+	if opts.StripMetadata {
+		// 移除 EXIF 后方向信息随之丢失，需要先把像素旋转到正常方向
+		orientation := 1
+		if format == FormatJPEG {
+			orientation = jpegOrientation(data)
+		}
+		if orientation > 1 {
+			img = applyOrientation(img, orientation)
+			var buf bytes.Buffer
+			if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: jpegQuality}); err != nil {
+				return nil, err
+			}
+			b := img.Bounds()
+			result.Original.Data, result.Original.Width, result.Original.Height = buf.Bytes(), b.Dx(), b.Dy()
+		} else if result.Original.Data, err = StripMetadata(format, data); err != nil {
+			return nil, err
+		}
+	}
+
+	if format == FormatPNG && opts.ReencodePNGSize > 0 && int64(len(result.Original.Data)) > opts.ReencodePNGSize {
+		var buf bytes.Buffer
+		encoder := png.Encoder{CompressionLevel: png.BestCompression}
+		if err := encoder.Encode(&buf, img); err != nil {
+			return nil, err
+		}
+		// 重新编码会丢弃所有辅助块，只在确实变小时使用
+		if buf.Len() < len(result.Original.Data) {
+			result.Original.Data = buf.Bytes()
+		}
+	}
+
+	if opts.ThumbnailSize > 0 && max(result.Original.Width, result.Original.Height) > opts.ThumbnailSize {
+		if result.Thumbnail, err = thumbnail(img, format, opts.ThumbnailSize); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// thumbnail 按比例缩放图片，最长边不超过 size，JPEG 保持 JPEG，其他格式输出 PNG 以保留透明度
+func thumbnail(src image.Image, format string, size int) (*Variant, error) {
+	b := src.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w >= h {
+		w, h = size, max(h*size/w, 1)
+	} else {
+		w, h = max(w*size/h, 1), size
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), src, b, draw.Src, nil)
+
+	var (
+		buf         bytes.Buffer
+		contentType string
+		err         error
+	)
+	if format == FormatJPEG {
+		contentType = "image/jpeg"
+		err = jpeg.Encode(&buf, dst, &jpeg.Options{Quality: thumbnailQuality})
+	} else {
+		contentType = "image/png"
+		err = png.Encode(&buf, dst)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &Variant{Data: buf.Bytes(), ContentType: contentType, Width: w, Height: h}, nil
+}
@@ -0,0 +1,119 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"testing"
+)
+
+// testJPEG 生成 w×h 的 JPEG，segments 插入在 SOI 之后
+func testJPEG(t *testing.T, w, h int, segments ...[]byte) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, color.RGBA{uint8(x * 40), uint8(y * 40), 128, 255})
+		}
+	}
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, nil); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+	out := append([]byte(nil), data[:2]...)
+	for _, segment := range segments {
+		out = append(out, segment...)
+	}
+	return append(out, data[2:]...)
+}
+
+// exifSegment 生成只包含方向标签的 APP1 段
+func exifSegment(order binary.ByteOrder, orientation uint16) []byte {
+	tiff := make([]byte, 8+2+12+4)
+	if order == binary.LittleEndian {
+		copy(tiff, "II")
+	} else {
+		copy(tiff, "MM")
+	}
+	order.PutUint16(tiff[2:], 42)
+	order.PutUint32(tiff[4:], 8)
+	order.PutUint16(tiff[8:], 1)
+	order.PutUint16(tiff[10:], exifOrientationTag)
+	order.PutUint16(tiff[12:], 3) // SHORT
+	order.PutUint32(tiff[14:], 1)
+	order.PutUint16(tiff[18:], orientation)
+
+	payload := append([]byte("Exif\x00\x00"), tiff...)
+	segment := []byte{0xFF, 0xE1, 0, 0}
+	binary.BigEndian.PutUint16(segment[2:], uint16(len(payload)+2))
+	return append(segment, payload...)
+}
+
+func TestJPEGOrientation(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want int
+	}{
+		{"没有 EXIF", testJPEG(t, 4, 4), 1},
+		{"大端方向 6", testJPEG(t, 4, 4, exifSegment(binary.BigEndian, 6)), 6},
+		{"小端方向 8", testJPEG(t, 4, 4, exifSegment(binary.LittleEndian, 8)), 8},
+		{"无效方向值", testJPEG(t, 4, 4, exifSegment(binary.BigEndian, 9)), 1},
+		{"填充字节", testJPEG(t, 4, 4, []byte{0xFF, 0xFF}, exifSegment(binary.BigEndian, 3)), 3},
+		{"RST 标记", testJPEG(t, 4, 4, []byte{0xFF, 0xD0}, exifSegment(binary.BigEndian, 5)), 5},
+		{"FF 00 后长度为 0", testJPEG(t, 4, 4, []byte{0xFF, 0x00, 0x00, 0x00}), 1},
+		{"段长度小于 2", testJPEG(t, 4, 4, []byte{0xFF, 0xE0, 0x00, 0x01}), 1},
+		{"段长度为 0", testJPEG(t, 4, 4, []byte{0xFF, 0xE0, 0x00, 0x00}), 1},
+		{"段长度超出数据", []byte{0xFF, 0xD8, 0xFF, 0xE1, 0xFF, 0xFF, 0x00}, 1},
+		{"截断的 EXIF", []byte{0xFF, 0xD8, 0xFF, 0xE1, 0x00, 0x08, 'E', 'x', 'i', 'f', 0, 0}, 1},
+		{"不是 JPEG", []byte("\x89PNG\r\n\x1a\n"), 1},
+		{"空数据", nil, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := jpegOrientation(tt.data); got != tt.want {
+				t.Errorf("jpegOrientation() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProcessOrientation(t *testing.T) {
+	data := testJPEG(t, 8, 4, exifSegment(binary.BigEndian, 6))
+	result, err := Process(data, Options{StripMetadata: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 方向 6 顺时针旋转 90 度，宽高互换
+	if result.Original.Width != 4 || result.Original.Height != 8 {
+		t.Errorf("size = %dx%d, want 4x8", result.Original.Width, result.Original.Height)
+	}
+	if bytes.Contains(result.Original.Data, []byte("Exif\x00\x00")) {
+		t.Error("EXIF was not removed")
+	}
+}
+
+// 回归测试：image/jpeg 可以解码长度字段无效的段，处理时不能越界
+func TestProcessMalformedSegment(t *testing.T) {
+	for _, segment := range [][]byte{
+		{0xFF, 0x00, 0x00, 0x00},
+		{0xFF, 0xE0, 0x00, 0x01},
+	} {
+		data := testJPEG(t, 4, 4, segment)
+		if _, _, err := image.Decode(bytes.NewReader(data)); err != nil {
+			// 解码器拒绝时 Process 直接返回错误，不会读取段
+			t.Logf("decoder rejects % X: %v", segment, err)
+		}
+		func() {
+			defer func() {
+				if r := recover(); r != nil {
+					t.Fatalf("Process panicked on % X: %v", segment, r)
+				}
+			}()
+			_, _ = Process(data, Options{StripMetadata: true, ThumbnailSize: 2})
+		}()
+	}
+}
@@ -0,0 +1,257 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+)
+
+var (
+	pngSignature = []byte("\x89PNG\r\n\x1a\n")
+
+	errMalformed = errors.New("malformed image data")
+)
+
+// StripMetadata 按字节移除图片中的元数据（EXIF、XMP、文本注释等），不重新编码像素数据
+// 支持所有允许上传的格式，其他格式原样返回
+func StripMetadata(format string, data []byte) ([]byte, error) {
+	switch format {
+	case FormatJPEG:
+		return stripJPEG(data)
+	case FormatPNG:
+		return stripPNG(data)
+	case FormatWebP:
+		return stripWebP(data)
+	case FormatGIF:
+		return stripGIF(data)
+	default:
+		return data, nil
+	}
+}
+
+// stripJPEG 移除 APP1（EXIF/XMP）、APP3-APP13、APP15 和 COM 段
+// 保留 APP0（JFIF）、APP2（ICC 颜色配置）和 APP14（Adobe 颜色变换），否则可能导致颜色错误
+func stripJPEG(data []byte) ([]byte, error) {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return nil, errMalformed
+	}
+
+	out := make([]byte, 0, len(data))
+	out = append(out, 0xFF, 0xD8)
+	for i := 2; i < len(data); {
+		if data[i] != 0xFF || i+1 >= len(data) {
+			return nil, errMalformed
+		}
+		marker := data[i+1]
+		switch {
+		case marker == 0xFF:
+			// 填充字节
+			i++
+			continue
+		case marker == 0xD9:
+			// EOI
+			return append(out, data[i:]...), nil
+		case marker == 0x00 || marker == 0x01 || (marker >= 0xD0 && marker <= 0xD7):
+			// 没有长度字段的标记
+			out = append(out, data[i:i+2]...)
+			i += 2
+			continue
+		}
+
+		if i+4 > len(data) {
+			return nil, errMalformed
+		}
+		end := i + 2 + int(binary.BigEndian.Uint16(data[i+2:]))
+		if end < i+4 || end > len(data) {
+			return nil, errMalformed
+		}
+		if marker == 0xDA {
+			// SOS 之后是压缩的图像数据，直接保留剩余部分
+			return append(out, data[i:]...), nil
+		}
+		if !isJPEGMetadata(marker) {
+			out = append(out, data[i:end]...)
+		}
+		i = end
+	}
+	return nil, errMalformed
+}
+
+func isJPEGMetadata(marker byte) bool {
+	switch {
+	case marker == 0xE1, marker == 0xFE, marker == 0xEF:
+		return true
+	case marker >= 0xE3 && marker <= 0xED:
+		return true
+	}
+	return false
+}
+
+// pngMetadataChunks 需要移除的 PNG 辅助块
+var pngMetadataChunks = map[string]bool{
+	"tEXt": true,
+	"zTXt": true,
+	"iTXt": true,
+	"eXIf": true,
+	"tIME": true,
+}
+
+// stripPNG 移除文本、EXIF 和时间块
+func stripPNG(data []byte) ([]byte, error) {
+	if !bytes.HasPrefix(data, pngSignature) {
+		return nil, errMalformed
+	}
+
+	out := make([]byte, 0, len(data))
+	out = append(out, pngSignature...)
+	for i := len(pngSignature); i < len(data); {
+		if i+8 > len(data) {
+			return nil, errMalformed
+		}
+		end := i + 12 + int(binary.BigEndian.Uint32(data[i:]))
+		if end > len(data) || end < i {
+			return nil, errMalformed
+		}
+		if !pngMetadataChunks[string(data[i+4:i+8])] {
+			out = append(out, data[i:end]...)
+		}
+		i = end
+	}
+	return out, nil
+}
+
+// WebP 扩展格式头中表示包含 EXIF 和 XMP 的标志位
+const (
+	webpFlagEXIF = 0x08
+	webpFlagXMP  = 0x04
+)
+
+// stripWebP 移除 EXIF 和 XMP 块，并同步更新 VP8X 标志位和 RIFF 长度
+func stripWebP(data []byte) ([]byte, error) {
+	if len(data) < 12 || string(data[0:4]) != "RIFF" || string(data[8:12]) != "WEBP" {
+		return nil, errMalformed
+	}
+
+	out := make([]byte, 0, len(data))
+	out = append(out, data[:12]...)
+	for i := 12; i < len(data); {
+		if i+8 > len(data) {
+			return nil, errMalformed
+		}
+		size := int(binary.LittleEndian.Uint32(data[i+4:]))
+		end := i + 8 + size + size&1 // 块按偶数字节对齐
+		if end > len(data) {
+			// 部分编码器省略最后一个块的填充字节
+			if end-1 != len(data) {
+				return nil, errMalformed
+			}
+			end = len(data)
+		}
+		switch string(data[i : i+4]) {
+		case "EXIF", "XMP ":
+		case "VP8X":
+			chunk := append([]byte(nil), data[i:end]...)
+			if len(chunk) > 8 {
+				chunk[8] &^= webpFlagEXIF | webpFlagXMP
+			}
+			out = append(out, chunk...)
+		default:
+			out = append(out, data[i:end]...)
+		}
+		i = end
+	}
+	binary.LittleEndian.PutUint32(out[4:], uint32(len(out)-8))
+	return out, nil
+}
+
+// GIF 块的引导字节和扩展标签
+const (
+	gifExtension       = 0x21
+	gifImageDescriptor = 0x2C
+	gifTrailer         = 0x3B
+	gifComment         = 0xFE
+	gifApplication     = 0xFF
+)
+
+// 需要保留的应用扩展：循环次数和 ICC 颜色配置，其他应用扩展（例如 XMP）会被移除
+var gifApplicationKeep = map[string]bool{
+	"NETSCAPE2.0": true,
+	"ANIMEXTS1.0": true,
+	"ICCRGBG1012": true,
+}
+
+// stripGIF 移除注释扩展和 XMP 等应用扩展，保留图形控制扩展、纯文本扩展和图像数据，丢弃结束符之后的数据
+func stripGIF(data []byte) ([]byte, error) {
+	if len(data) < 13 || (string(data[:6]) != "GIF87a" && string(data[:6]) != "GIF89a") {
+		return nil, errMalformed
+	}
+
+	// 文件头、逻辑屏幕描述符和全局颜色表
+	i := 13
+	if flags := data[10]; flags&0x80 != 0 {
+		i += 3 << (flags&0x07 + 1)
+	}
+	if i > len(data) {
+		return nil, errMalformed
+	}
+	out := make([]byte, 0, len(data))
+	out = append(out, data[:i]...)
+	for i < len(data) {
+		start := i
+		switch data[i] {
+		case gifTrailer:
+			return append(out, gifTrailer), nil
+		case gifExtension:
+			if i+2 > len(data) {
+				return nil, errMalformed
+			}
+			label := data[i+1]
+			end, ok := gifSubBlocks(data, i+2)
+			if !ok {
+				return nil, errMalformed
+			}
+			i = end
+			if label == gifComment {
+				continue
+			}
+			if label == gifApplication {
+				// 应用扩展的第一个子块是 11 字节的应用标识和认证码
+				if start+3+11 > end || data[start+2] != 11 || !gifApplicationKeep[string(data[start+3:start+14])] {
+					continue
+				}
+			}
+			out = append(out, data[start:end]...)
+		case gifImageDescriptor:
+			if i+10 > len(data) {
+				return nil, errMalformed
+			}
+			i += 10
+			if flags := data[i-1]; flags&0x80 != 0 {
+				i += 3 << (flags&0x07 + 1)
+			}
+			// LZW 最小码长之后是图像数据子块
+			end, ok := gifSubBlocks(data, i+1)
+			if !ok {
+				return nil, errMalformed
+			}
+			i = end
+			out = append(out, data[start:end]...)
+		default:
+			return nil, errMalformed
+		}
+	}
+	return nil, errMalformed
+}
+
+// gifSubBlocks 跳过从 i 开始的数据子块序列，返回结束块之后的位置
+func gifSubBlocks(data []byte, i int) (int, bool) {
+	for i < len(data) {
+		size := int(data[i])
+		i++
+		if size == 0 {
+			return i, true
+		}
+		i += size
+	}
+	return 0, false
+}
@@ -0,0 +1,204 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/color/palette"
+	"image/gif"
+	"image/png"
+	"testing"
+)
+
+// testPNG 生成 2×2 的 PNG，chunks 插入在 IHDR 之后
+func testPNG(t *testing.T, chunks ...[]byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewGray(image.Rect(0, 0, 2, 2))); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+	ihdrEnd := len(pngSignature) + 12 + 13
+	out := append([]byte(nil), data[:ihdrEnd]...)
+	for _, chunk := range chunks {
+		out = append(out, chunk...)
+	}
+	return append(out, data[ihdrEnd:]...)
+}
+
+// pngChunk 生成带 CRC 的 PNG 块
+func pngChunk(typ string, payload []byte) []byte {
+	chunk := binary.BigEndian.AppendUint32(nil, uint32(len(payload)))
+	chunk = append(chunk, typ...)
+	chunk = append(chunk, payload...)
+	return binary.BigEndian.AppendUint32(chunk, crc32.ChecksumIEEE(chunk[4:]))
+}
+
+// testGIF 生成两帧的循环 GIF，extensions 插入在第一个扩展块（NETSCAPE2.0）之前
+func testGIF(t *testing.T, extensions ...[]byte) []byte {
+	t.Helper()
+	frame := func(c uint8) *image.Paletted {
+		img := image.NewPaletted(image.Rect(0, 0, 2, 2), palette.Plan9)
+		img.SetColorIndex(0, 0, c)
+		return img
+	}
+	var buf bytes.Buffer
+	if err := gif.EncodeAll(&buf, &gif.GIF{Image: []*image.Paletted{frame(1), frame(2)}, Delay: []int{10, 10}}); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+	i := bytes.Index(data, []byte("\x21\xFF\x0BNETSCAPE2.0"))
+	if i < 0 {
+		t.Fatal("NETSCAPE2.0 extension not found")
+	}
+	out := append([]byte(nil), data[:i]...)
+	for _, extension := range extensions {
+		out = append(out, extension...)
+	}
+	return append(out, data[i:]...)
+}
+
+func TestStripMetadata(t *testing.T) {
+	iccSegment := []byte{0xFF, 0xE2, 0x00, 0x0E, 'I', 'C', 'C', '_', 'P', 'R', 'O', 'F', 'I', 'L', 'E', 0x00}
+	comSegment := []byte{0xFF, 0xFE, 0x00, 0x07, 's', 'e', 'c', 'r', 'e'}
+	gifComment := []byte{0x21, 0xFE, 0x05, 's', 'e', 'c', 'r', 'e', 0x00}
+	gifXMP := append([]byte{0x21, 0xFF, 0x0B}, "XMP DataXMP"...)
+	gifXMP = append(gifXMP, 0x05, 's', 'e', 'c', 'r', 'e', 0x00)
+
+	tests := []struct {
+		name    string
+		format  string
+		data    []byte
+		removed []string // 处理后不应包含的内容
+		kept    []string // 处理后仍应包含的内容
+		wantErr bool
+	}{
+		{
+			name:    "JPEG 移除 EXIF 和注释，保留 ICC",
+			format:  FormatJPEG,
+			data:    testJPEG(t, 4, 4, exifSegment(binary.BigEndian, 1), iccSegment, comSegment),
+			removed: []string{"Exif\x00\x00", "secre"},
+			kept:    []string{"ICC_PROFILE"},
+		},
+		{
+			name:    "JPEG 填充字节和 FF 00",
+			format:  FormatJPEG,
+			data:    testJPEG(t, 4, 4, []byte{0xFF, 0xFF, 0xFF, 0x00}, exifSegment(binary.BigEndian, 1)),
+			removed: []string{"Exif\x00\x00"},
+		},
+		{
+			name:    "JPEG 段长度小于 2",
+			format:  FormatJPEG,
+			data:    testJPEG(t, 4, 4, []byte{0xFF, 0xE0, 0x00, 0x01}),
+			wantErr: true,
+		},
+		{
+			name:    "JPEG 截断",
+			format:  FormatJPEG,
+			data:    []byte{0xFF, 0xD8, 0xFF, 0xE1, 0x00, 0x10, 'E'},
+			wantErr: true,
+		},
+		{
+			name:    "PNG 移除文本和 EXIF 块",
+			format:  FormatPNG,
+			data:    testPNG(t, pngChunk("tEXt", []byte("Author\x00secre")), pngChunk("eXIf", []byte("MM\x00*secre")), pngChunk("gAMA", []byte{0, 0, 0xB1, 0x8F})),
+			removed: []string{"tEXt", "eXIf", "secre"},
+			kept:    []string{"gAMA", "IDAT", "IEND"},
+		},
+		{
+			name:    "PNG 块长度超出数据",
+			format:  FormatPNG,
+			data:    append(append([]byte(nil), pngSignature...), 0xFF, 0xFF, 0xFF, 0xFF, 'I', 'H', 'D', 'R'),
+			wantErr: true,
+		},
+		{
+			name:    "GIF 移除注释和 XMP，保留循环扩展",
+			format:  FormatGIF,
+			data:    testGIF(t, gifComment, gifXMP),
+			removed: []string{"secre", "XMP DataXMP"},
+			kept:    []string{"NETSCAPE2.0"},
+		},
+		{
+			name:    "GIF 结束符之后的数据",
+			format:  FormatGIF,
+			data:    append(testGIF(t), "secre"...),
+			removed: []string{"secre"},
+		},
+		{
+			name:    "GIF 子块截断",
+			format:  FormatGIF,
+			data:    testGIF(t)[:40],
+			wantErr: true,
+		},
+		{
+			name:    "WebP 移除 EXIF 和 XMP",
+			format:  FormatWebP,
+			data:    testWebP([]byte{webpFlagEXIF | webpFlagXMP, 0, 0, 0, 0, 0, 0, 0, 0, 0}, "secre"),
+			removed: []string{"EXIF", "XMP ", "secre"},
+			kept:    []string{"VP8X", "VP8L"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := StripMetadata(tt.format, tt.data)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("StripMetadata() succeeded, want error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("StripMetadata() error = %v", err)
+			}
+			for _, s := range tt.removed {
+				if bytes.Contains(got, []byte(s)) {
+					t.Errorf("result still contains %q", s)
+				}
+			}
+			for _, s := range tt.kept {
+				if !bytes.Contains(got, []byte(s)) {
+					t.Errorf("result lost %q", s)
+				}
+			}
+			if tt.format == FormatWebP {
+				return
+			}
+			// 移除元数据后仍能正常解码
+			if _, format, err := image.Decode(bytes.NewReader(got)); err != nil || format != tt.format {
+				t.Errorf("decode stripped image: format %q, error %v", format, err)
+			}
+		})
+	}
+}
+
+// testWebP 生成只包含 VP8X、EXIF、XMP 和占位 VP8L 块的 WebP 容器，只用于测试按字节移除元数据
+func testWebP(vp8x []byte, secret string) []byte {
+	chunk := func(typ string, payload []byte) []byte {
+		c := append([]byte(typ), binary.LittleEndian.AppendUint32(nil, uint32(len(payload)))...)
+		c = append(c, payload...)
+		if len(payload)%2 == 1 {
+			c = append(c, 0)
+		}
+		return c
+	}
+	body := []byte("WEBP")
+	body = append(body, chunk("VP8X", vp8x)...)
+	body = append(body, chunk("EXIF", []byte(secret))...)
+	body = append(body, chunk("XMP ", []byte(secret))...)
+	body = append(body, chunk("VP8L", []byte{0x2F, 0, 0, 0, 0})...)
+	return append(append([]byte("RIFF"), binary.LittleEndian.AppendUint32(nil, uint32(len(body)))...), body...)
+}
+
+func TestStripWebPFlags(t *testing.T) {
+	got, err := StripMetadata(FormatWebP, testWebP([]byte{webpFlagEXIF | webpFlagXMP | 0x10, 0, 0, 0, 0, 0, 0, 0, 0, 0}, "x"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if flags := got[20]; flags != 0x10 {
+		t.Errorf("VP8X flags = %#x, want 0x10", flags)
+	}
+	if size := binary.LittleEndian.Uint32(got[4:]); int(size) != len(got)-8 {
+		t.Errorf("RIFF size = %d, want %d", size, len(got)-8)
+	}
+}
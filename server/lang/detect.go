@@ -0,0 +1,355 @@
+package lang
+
+import (
+	"encoding/json"
+	"math"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// 自动识别的置信度低于该值时不采用识别结果，保留纯文本
+const MinConfidence = 0.4
+
+// 只分析片段开头的内容，避免超大片段拖慢创建请求
+const maxDetectSize = 64 << 10
+
+// 解释器名称（去掉版本号）对应的语言类型
+var interpreters = map[string]string{
+	"python":  "python",
+	"bash":    "bash",
+	"sh":      "bash",
+	"zsh":     "bash",
+	"ksh":     "bash",
+	"dash":    "bash",
+	"node":    "javascript",
+	"nodejs":  "javascript",
+	"deno":    "javascript",
+	"ts-node": "typescript",
+	"ruby":    "ruby",
+	"perl":    "perl",
+	"php":     "php",
+	"lua":     "lua",
+	"pwsh":    "powershell",
+	"make":    "makefile",
+}
+
+var (
+	goPackage = regexp.MustCompile(`(?m)^package \w+[ \t]*$`)
+	goDecl    = regexp.MustCompile(`(?m)^(func|import|type|var|const) `)
+	diffHunk  = regexp.MustCompile(`(?m)^--- .*\n\+\+\+ .*\n@@ `)
+)
+
+// feature 表示一种语言的特征记号，每次出现计 weight 分
+type feature struct {
+	pattern *regexp.Regexp
+	weight  float64
+}
+
+// 同一个特征最多计分的次数，避免大量重复的简单记号压过其他特征
+const maxFeatureHits = 5
+
+// 识别需要的最低分数，内容太少时不做判断
+const minScore = 4
+
+func features(weights map[string]float64) []feature {
+	fs := make([]feature, 0, len(weights))
+	for pattern, weight := range weights {
+		fs = append(fs, feature{pattern: regexp.MustCompile(`(?m)` + pattern), weight: weight})
+	}
+	return fs
+}
+
+// 各语言的特征记号
+var languageFeatures = map[string][]feature{
+	"python": features(map[string]float64{
+		`^[ \t]*def \w+\(.*\):[ \t]*$`:        3,
+		`^from [\w.]+ import `:                3,
+		`^import [\w.]+(\s+as \w+)?[ \t]*$`:   2,
+		`\bself\.\w+`:                         2,
+		`\belif\b`:                            3,
+		`^[ \t]*(if|for|while|with|try) .*:$`: 1,
+		`__name__|__init__`:                   3,
+		`\bprint\(`:                           1,
+		`\b(None|True|False)\b`:               0.5,
+	}),
+	"go": features(map[string]float64{
+		`^package \w+[ \t]*$`:                3,
+		`\bfunc\s+(\(\w+ \*?\w+\)\s*)?\w+\(`: 3,
+		`:=`:                                 1,
+		`\bfmt\.\w+\(`:                       2,
+		`\berr != nil\b`:                     3,
+		`^import \($`:                        3,
+		`\bchan\b|\bgo func\b|\bdefer\b`:     2,
+	}),
+	"javascript": features(map[string]float64{
+		`\b(const|let|var) \w+ = `:            1,
+		`=>`:                                  1,
+		`\bfunction\s*\w*\s*\(`:               2,
+		`\bconsole\.log\(`:                    3,
+		`\brequire\(['"]`:                     2,
+		`\b(document|window)\.\w+`:            2,
+		`===|!==`:                             2,
+		`\bmodule\.exports\b|^export default`: 2,
+	}),
+	"typescript": features(map[string]float64{
+		`\w\??:\s*(string|number|boolean|any|void|unknown)\b`: 3,
+		`\binterface \w+\s*\{`:                                2,
+		`^export (type|interface|enum) `:                      3,
+		`^import .* from ['"]`:                                1,
+		`\b(const|let) \w+ = `:                                0.5,
+	}),
+	"java": features(map[string]float64{
+		`\bpublic (static |final |abstract )*(class|void|interface)\b`: 3,
+		`\bSystem\.out\.print`:                      4,
+		`^import [\w.]+(\.\*)?;`:                    3,
+		`^package [\w.]+;`:                          3,
+		`@Override\b`:                               3,
+		`\bString\[\] args\b`:                       4,
+		`\b(private|protected) \w+(<[\w, ]+>)? \w+`: 1,
+	}),
+	"c": features(map[string]float64{
+		`^#include\s*<\w+\.h>`:     3,
+		`^#include\s*"`:            2,
+		`^#define \w+`:             2,
+		`\bprintf\(`:               2,
+		`\bint main\(`:             2,
+		`\b(malloc|free|sizeof)\(`: 2,
+		`->`:                       0.5,
+	}),
+	"cpp": features(map[string]float64{
+		`^#include\s*<\w+>`:     3,
+		`\bstd::`:               3,
+		`\b(cout|cin|endl)\b`:   3,
+		`\btemplate\s*<`:        3,
+		`^using namespace \w+;`: 4,
+		`\b(class|struct) \w+\s*(:\s*public \w+)?\s*\{`: 1,
+	}),
+	"csharp": features(map[string]float64{
+		`^using System(\.\w+)*;`:     4,
+		`\bnamespace [\w.]+`:         1,
+		`\bConsole\.Write(Line)?\(`:  4,
+		`\bpublic (async )?Task\b`:   3,
+		`\{ get; (private )?set; \}`: 4,
+		`\bvar \w+ = new \w+`:        2,
+	}),
+	"rust": features(map[string]float64{
+		`\bfn \w+(<[^\n>]*>)?\(`:     2,
+		`\blet mut\b`:                4,
+		`^[ \t]*impl\b`:              3,
+		`\b(println|format|vec)!`:    4,
+		`^use \w+(::\w+)+`:           3,
+		`&(mut |'\w+ )?(str|self)\b`: 3,
+		`\bOption<|\bResult<`:        2,
+	}),
+	"php": features(map[string]float64{
+		`\$this->`:                      4,
+		`\bfunction \w+\(\$`:            3,
+		`\$\w+\s*=\s*`:                  0.5,
+		`\becho\b`:                      0.5,
+		`\b(public|private) function\b`: 3,
+	}),
+	"ruby": features(map[string]float64{
+		`^[ \t]*def \w+[?!]?(\(.*\))?[ \t]*$`: 2,
+		`^[ \t]*end[ \t]*$`:                   1,
+		`\bputs\b`:                            2,
+		`\battr_(accessor|reader|writer)\b`:   4,
+		`^require ['"]`:                       3,
+		`\.each do\b|\bdo \|\w+(, \w+)*\|`:    3,
+		`^[ \t]*class \w+ < \w+`:              3,
+	}),
+	"perl": features(map[string]float64{
+		`^use (strict|warnings);`: 4,
+		`\bmy [$@%]\w+`:           3,
+		`\$_\b|@_\b`:              2,
+		`=~ [ms]?/`:               2,
+		`^[ \t]*sub \w+\s*\{`:     3,
+	}),
+	"bash": features(map[string]float64{
+		`^[ \t]*(if|while|until) \[\[? `:             3,
+		`^[ \t]*(fi|done|esac)[ \t]*$`:               3,
+		`\$\{\w+[^\n}]*\}|\$\(`:                      1,
+		`^[ \t]*export \w+=`:                         2,
+		`\becho\b`:                                   1,
+		`\|\s*(grep|awk|sed|xargs|sort|head|tail)\b`: 2,
+		`^[ \t]*(sudo|apt-get|apt|yum|brew|npm|pip3?|cd|ls|mkdir|rm|cp|mv|curl|wget|git|docker|kubectl|chmod|tar) `: 1,
+		`&&\s*\\?$|2>&1`: 1,
+	}),
+	"powershell": features(map[string]float64{
+		`\b(Get|Set|New|Remove|Write|Invoke)-\w+`: 3,
+		`\$\w+\s*=`:                   0.5,
+		`-(eq|ne|lt|gt|like|match)\b`: 2,
+		`^[ \t]*param\s*\(`:           3,
+	}),
+	"sql": features(map[string]float64{
+		`(?i)\bselect\b.+\bfrom\b`:                     3,
+		`(?i)\binsert\s+into\b`:                        4,
+		`(?i)\bcreate\s+(table|index|view|database)\b`: 4,
+		`(?i)\bupdate\s+\w+\s+set\b`:                   4,
+		`(?i)\bdelete\s+from\b`:                        4,
+		`(?i)\b(where|group by|order by)\b`:            1,
+		`(?i)\b(inner|left|right)?\s*join\b`:           1,
+	}),
+	"css": features(map[string]float64{
+		`^[ \t]*[.#@]?[\w-]+([\s,>+~:.#\[\]="\w-]*)\{[ \t]*$`: 2,
+		`^[ \t]*[\w-]+\s*:\s*[^\n;{}]+;[ \t]*$`:               1,
+		`\b\d+(px|em|rem|vh|vw)\b`:                            2,
+		`@media\b|@import\b|@keyframes\b`:                     3,
+		`#[0-9a-fA-F]{3,6}\b`:                                 1,
+	}),
+	"html": features(map[string]float64{
+		`</?(div|span|p|a|body|head|script|table|tr|td|ul|li|img|form|input)\b[^\n>]*>`: 2,
+		`\b(class|href|src)="[^\n"]*"`: 1,
+	}),
+	"xml": features(map[string]float64{
+		`</[\w-]+:[\w-]+>`:                  2,
+		`\bxmlns(:\w+)?="`:                  3,
+		`<[\w-]+( [\w:-]+="[^\n"]*")*\s*/>`: 1,
+	}),
+	"yaml": features(map[string]float64{
+		`^[ \t]*[\w-]+:[ \t]*$`:      1,
+		`^[ \t]*[\w-]+: [^\n{};=]+$`: 1,
+		`^[ \t]*- [\w"'][^\n;]*$`:    1,
+		`^---[ \t]*$`:                2,
+	}),
+	"markdown": features(map[string]float64{
+		`^#{1,6} \S`:                  2,
+		`^[ \t]*([-*+]|\d+\.) \S`:     0.5,
+		`\[[^\n\]]+\]\([^\n)]+\)`:     2,
+		"^```":                        3,
+		`\*\*[^\n*]+\*\*|__[^\n_]+__`: 1,
+		`^> \S`:                       1,
+	}),
+	"toml": features(map[string]float64{
+		`^\[\[?[\w."-]+\]\]?[ \t]*$`:          2,
+		`^[\w-]+ = ("|'|\d|true|false|\[|\{)`: 2,
+	}),
+	"ini": features(map[string]float64{
+		`^\[[\w .-]+\][ \t]*$`:        2,
+		`^[\w.-]+\s*=\s*[^\n"'\[{]*$`: 1,
+		`^;`:                          1,
+	}),
+	"dockerfile": features(map[string]float64{
+		`^FROM \S+`: 4,
+		`^(RUN|COPY|ADD|ENTRYPOINT|CMD|WORKDIR|EXPOSE|ENV|ARG|LABEL) `: 2,
+	}),
+	"makefile": features(map[string]float64{
+		`^\.PHONY:`:              4,
+		`^[\w./-]+:( [^\n=]*)?$`: 0.5,
+		`\$\(\w+\)|\$[@<^]`:      1,
+		`^\t\S`:                  0.5,
+	}),
+	"lua": features(map[string]float64{
+		`\blocal \w+ = `:       3,
+		`\bfunction [\w.:]+\(`: 1,
+		`\bthen\b`:             1,
+		`~=`:                   2,
+		`^[ \t]*end[ \t]*$`:    1,
+		`\b(ipairs|pairs)\(`:   3,
+	}),
+	"kotlin": features(map[string]float64{
+		`\bfun \w+\(`:          3,
+		`\bval \w+(: \w+)? = `: 2,
+		`\bprintln\(`:          1,
+		`\bdata class\b`:       4,
+	}),
+	"swift": features(map[string]float64{
+		`^import (UIKit|Foundation|SwiftUI)`: 4,
+		`\bfunc \w+\(.*\) -> `:               2,
+		`\bguard let\b|\bif let\b`:           3,
+		`\bvar \w+: \w+`:                     1,
+	}),
+}
+
+// Detect 识别内容的语言类型，依次尝试 shebang、文件类型特征和记号统计，
+// 返回语言类型和 0 到 1 之间的置信度，无法识别或置信度低于 MinConfidence 时返回空字符串
+func Detect(content string) (string, float64) {
+	if len(content) > maxDetectSize {
+		content = strings.ToValidUTF8(content[:maxDetectSize], "")
+	}
+	content = strings.ReplaceAll(content, "\r\n", "\n")
+	trimmed := strings.TrimSpace(content)
+	if trimmed == "" {
+		return "", 0
+	}
+	if langtype := byShebang(trimmed); langtype != "" {
+		return langtype, 1
+	}
+	if langtype, confidence := byFileType(trimmed); langtype != "" {
+		return langtype, confidence
+	}
+	langtype, confidence := byTokens(content)
+	if confidence < MinConfidence {
+		return "", 0
+	}
+	return langtype, confidence
+}
+
+// byShebang 根据第一行的解释器识别脚本，例如 #!/usr/bin/env python3
+func byShebang(content string) string {
+	line, _, _ := strings.Cut(content, "\n")
+	if !strings.HasPrefix(line, "#!") {
+		return ""
+	}
+	fields := strings.Fields(line[2:])
+	if len(fields) == 0 {
+		return ""
+	}
+	interpreter := path.Base(fields[0])
+	if interpreter == "env" {
+		interpreter = ""
+		for _, field := range fields[1:] {
+			// 跳过 env 的参数，例如 env -S
+			if !strings.HasPrefix(field, "-") {
+				interpreter = field
+				break
+			}
+		}
+	}
+	return interpreters[strings.TrimRight(interpreter, "0123456789.")]
+}
+
+// byFileType 根据文件开头的声明或整体结构识别，结果比记号统计可靠
+func byFileType(content string) (string, float64) {
+	lower := strings.ToLower(content[:min(len(content), 64)])
+	switch {
+	case strings.HasPrefix(lower, "<?php"):
+		return "php", 0.95
+	case strings.HasPrefix(lower, "<?xml"):
+		return "xml", 0.95
+	case strings.HasPrefix(lower, "<!doctype html"), strings.HasPrefix(lower, "<html"):
+		return "html", 0.95
+	case strings.HasPrefix(content, "diff --git "), diffHunk.MatchString(content):
+		return "diff", 0.95
+	case (content[0] == '{' || content[0] == '[') && json.Valid([]byte(content)):
+		return "json", 0.95
+	case goPackage.MatchString(content) && goDecl.MatchString(content):
+		return "go", 0.9
+	}
+	return "", 0
+}
+
+// byTokens 统计各语言特征记号的得分，得分最高的语言占总分的比例越大、得分越高，置信度越高
+func byTokens(content string) (string, float64) {
+	var (
+		best      string
+		bestScore float64
+		total     float64
+	)
+	for langtype, fs := range languageFeatures {
+		var score float64
+		for _, f := range fs {
+			score += float64(len(f.pattern.FindAllStringIndex(content, maxFeatureHits))) * f.weight
+		}
+		total += score
+		// 同分时按名称选择，保证结果稳定
+		if score > bestScore || (score == bestScore && score > 0 && langtype < best) {
+			best, bestScore = langtype, score
+		}
+	}
+	if bestScore < minScore {
+		return "", 0
+	}
+	confidence := bestScore / total * bestScore / (bestScore + minScore)
+	return best, math.Round(confidence*100) / 100
+}
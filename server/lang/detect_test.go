@@ -0,0 +1,98 @@
+package lang
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{"空内容", "  \n\t", ""},
+		{"普通文本", "Meeting notes: remember to buy milk and call Bob.", ""},
+		{"shebang python", "#!/usr/bin/env python3\nprint('hi')\n", "python"},
+		{"shebang env -S", "#!/usr/bin/env -S node --no-warnings\nconsole.log(1)\n", "javascript"},
+		{"shebang bash", "#!/bin/bash\necho hi\n", "bash"},
+		{"php 声明", "<?php echo 'hi'; ?>", "php"},
+		{"xml 声明", "<?xml version=\"1.0\"?>\n<a/>", "xml"},
+		{"html", "<!DOCTYPE html>\n<html><body></body></html>", "html"},
+		{"git diff", "diff --git a/x b/x\n--- a/x\n+++ b/x\n@@ -1 +1 @@\n-a\n+b\n", "diff"},
+		{"统一 diff", "--- a/x\n+++ b/x\n@@ -1 +1 @@\n-a\n+b\n", "diff"},
+		{"json", `{"name": "paste", "tags": [1, 2]}`, "json"},
+		{"无效的 json", `{"name": "paste",}`, ""},
+		{"go", "package main\n\nimport \"fmt\"\n\nfunc main() {\n\tfmt.Println(1)\n}\n", "go"},
+		{"python", strings.Join([]string{
+			"from os import path",
+			"import sys",
+			"",
+			"class Foo:",
+			"    def __init__(self):",
+			"        self.x = None",
+			"",
+			"    def run(self):",
+			"        if self.x:",
+			"            print(self.x)",
+			"        elif True:",
+			"            pass",
+		}, "\n"), "python"},
+		{"go 不带 package", strings.Join([]string{
+			"func (s *Server) Run() error {",
+			"\tresult, err := s.do()",
+			"\tif err != nil {",
+			"\t\treturn fmt.Errorf(\"run: %w\", err)",
+			"\t}",
+			"\tdefer s.Close()",
+			"\treturn nil",
+			"}",
+		}, "\n"), "go"},
+		{"windows 换行", "#!/bin/sh\r\necho hi\r\n", "bash"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, confidence := Detect(tt.content)
+			if got != tt.want {
+				t.Fatalf("Detect() = %q (%.2f), want %q", got, confidence, tt.want)
+			}
+			if got != "" && (confidence < MinConfidence || confidence > 1) {
+				t.Errorf("Detect() confidence = %.2f, want between %.2f and 1", confidence, MinConfidence)
+			}
+			if got == "" && confidence != 0 {
+				t.Errorf("Detect() confidence = %.2f for undetected content, want 0", confidence)
+			}
+			if _, ok := Lookup(got); got != "" && !ok {
+				t.Errorf("Detect() = %q, not a registered language", got)
+			}
+		})
+	}
+}
+
+func TestDetectLargeContent(t *testing.T) {
+	// 只分析开头的内容，截断位置落在多字节字符中间时也不能产生无效的 UTF-8
+	content := "#!/usr/bin/env python3\n" + strings.Repeat("中", maxDetectSize)
+	if got, _ := Detect(content); got != "python" {
+		t.Errorf("Detect() = %q, want python", got)
+	}
+}
+
+func TestByShebang(t *testing.T) {
+	tests := []struct {
+		line string
+		want string
+	}{
+		{"#!/usr/bin/python3.11", "python"},
+		{"#!/usr/bin/env ruby", "ruby"},
+		{"#!/usr/local/bin/zsh -e", "bash"},
+		{"#!/usr/bin/env", ""},
+		{"#!", ""},
+		{"#!/usr/bin/unknown", ""},
+		{"# comment", ""},
+	}
+	for _, tt := range tests {
+		if got := byShebang(tt.line + "\nbody"); got != tt.want {
+			t.Errorf("byShebang(%q) = %q, want %q", tt.line, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package lang
+
+import (
+	"slices"
+	"strings"
+)
+
+// 纯文本的语言类型，与前端表单的默认值一致
+const Plain = "plain"
+
+// Language 表示一种支持高亮的语言类型
+type Language struct {
+	ID         string   // 写入 Snippet.Langtype 的规范值，同时是 chroma 的 lexer 名称
+	Name       string   // 显示名称
+	Aliases    []string // 创建分享时可以使用的别名，保存时转换为 ID
+	Extensions []string // 文件扩展名，第一个用于下载时的文件名
+	MIMEType   string   // 下载时使用的 MIME 类型
+}
+
+// Extension 返回下载时使用的文件扩展名
+func (l Language) Extension() string {
+	if len(l.Extensions) == 0 {
+		return ".txt"
+	}
+	return l.Extensions[0]
+}
+
+// 支持的语言类型，按 ID 排序，纯文本在最前
+var languages = []Language{
+	{ID: Plain, Name: "Plain Text", Aliases: []string{"text", "txt", "plaintext", "none"}, Extensions: []string{".txt", ".text", ".log"}, MIMEType: "text/plain"},
+	{ID: "bash", Name: "Bash", Aliases: []string{"sh", "shell", "zsh", "ksh"}, Extensions: []string{".sh", ".bash", ".zsh"}, MIMEType: "application/x-sh"},
+	{ID: "c", Name: "C", Extensions: []string{".c", ".h"}, MIMEType: "text/x-c"},
+	{ID: "cpp", Name: "C++", Aliases: []string{"c++", "cxx"}, Extensions: []string{".cpp", ".cc", ".cxx", ".hpp", ".hh"}, MIMEType: "text/x-c++src"},
+	{ID: "csharp", Name: "C#", Aliases: []string{"c#", "cs"}, Extensions: []string{".cs"}, MIMEType: "text/x-csharp"},
+	{ID: "css", Name: "CSS", Extensions: []string{".css"}, MIMEType: "text/css"},
+	{ID: "diff", Name: "Diff", Aliases: []string{"patch", "udiff"}, Extensions: []string{".diff", ".patch"}, MIMEType: "text/x-diff"},
+	{ID: "dockerfile", Name: "Dockerfile", Aliases: []string{"docker"}, Extensions: []string{".dockerfile"}, MIMEType: "text/x-dockerfile"},
+	{ID: "go", Name: "Go", Aliases: []string{"golang"}, Extensions: []string{".go"}, MIMEType: "text/x-go"},
+	{ID: "html", Name: "HTML", Aliases: []string{"xhtml"}, Extensions: []string{".html", ".htm"}, MIMEType: "text/html"},
+	{ID: "ini", Name: "INI", Aliases: []string{"cfg", "dosini"}, Extensions: []string{".ini", ".cfg", ".conf"}, MIMEType: "text/plain"},
+	{ID: "java", Name: "Java", Extensions: []string{".java"}, MIMEType: "text/x-java"},
+	{ID: "javascript", Name: "JavaScript", Aliases: []string{"js", "node", "nodejs"}, Extensions: []string{".js", ".mjs", ".cjs"}, MIMEType: "text/javascript"},
+	{ID: "json", Name: "JSON", Extensions: []string{".json"}, MIMEType: "application/json"},
+	{ID: "kotlin", Name: "Kotlin", Aliases: []string{"kt"}, Extensions: []string{".kt", ".kts"}, MIMEType: "text/x-kotlin"},
+	{ID: "lua", Name: "Lua", Extensions: []string{".lua"}, MIMEType: "text/x-lua"},
+	{ID: "makefile", Name: "Makefile", Aliases: []string{"make", "mk"}, Extensions: []string{".mk"}, MIMEType: "text/x-makefile"},
+	{ID: "markdown", Name: "Markdown", Aliases: []string{"md"}, Extensions: []string{".md", ".markdown"}, MIMEType: "text/markdown"},
+	{ID: "perl", Name: "Perl", Aliases: []string{"pl"}, Extensions: []string{".pl", ".pm"}, MIMEType: "text/x-perl"},
+	{ID: "php", Name: "PHP", Extensions: []string{".php"}, MIMEType: "application/x-httpd-php"},
+	{ID: "powershell", Name: "PowerShell", Aliases: []string{"pwsh", "posh", "ps1"}, Extensions: []string{".ps1", ".psm1"}, MIMEType: "text/x-powershell"},
+	{ID: "python", Name: "Python", Aliases: []string{"py", "python3", "py3"}, Extensions: []string{".py", ".pyw"}, MIMEType: "text/x-python"},
+	{ID: "ruby", Name: "Ruby", Aliases: []string{"rb"}, Extensions: []string{".rb"}, MIMEType: "text/x-ruby"},
+	{ID: "rust", Name: "Rust", Aliases: []string{"rs"}, Extensions: []string{".rs"}, MIMEType: "text/rust"},
+	{ID: "sql", Name: "SQL", Aliases: []string{"mysql", "postgresql", "postgres", "sqlite"}, Extensions: []string{".sql"}, MIMEType: "application/sql"},
+	{ID: "swift", Name: "Swift", Extensions: []string{".swift"}, MIMEType: "text/x-swift"},
+	{ID: "toml", Name: "TOML", Extensions: []string{".toml"}, MIMEType: "application/toml"},
+	{ID: "typescript", Name: "TypeScript", Aliases: []string{"ts"}, Extensions: []string{".ts", ".tsx"}, MIMEType: "application/typescript"},
+	{ID: "xml", Name: "XML", Extensions: []string{".xml", ".xsd"}, MIMEType: "application/xml"},
+	{ID: "yaml", Name: "YAML", Aliases: []string{"yml"}, Extensions: []string{".yaml", ".yml"}, MIMEType: "application/yaml"},
+}
+
+// 按 ID、别名和不带点的扩展名索引语言类型，均为小写
+var index = func() map[string]Language {
+	m := make(map[string]Language)
+	for _, l := range languages {
+		for _, name := range append([]string{l.ID}, l.Aliases...) {
+			m[name] = l
+		}
+	}
+	// ID 和别名优先于其他语言的扩展名
+	for _, l := range languages {
+		for _, ext := range l.Extensions {
+			if name := strings.TrimPrefix(ext, "."); m[name].ID == "" {
+				m[name] = l
+			}
+		}
+	}
+	return m
+}()
+
+// Languages 返回支持的语言类型
+func Languages() []Language {
+	return slices.Clone(languages)
+}
+
+// Lookup 按 ID、别名或扩展名查找语言类型，不区分大小写，例如 golang、Go、.go 都返回 go
+func Lookup(name string) (Language, bool) {
+	name = strings.TrimPrefix(strings.ToLower(strings.TrimSpace(name)), ".")
+	l, ok := index[name]
+	return l, ok
+}
+
+// Normalize 将 langtype 转换为规范的 ID，为空时返回纯文本，不支持的语言类型返回 false
+func Normalize(langtype string) (string, bool) {
+	if strings.TrimSpace(langtype) == "" {
+		return Plain, true
+	}
+	l, ok := Lookup(langtype)
+	return l.ID, ok
+}
+
+// IsUndetermined 判断 langtype 是否为空或纯文本，此时需要自动识别语言
+func IsUndetermined(langtype string) bool {
+	id, ok := Normalize(langtype)
+	return ok && id == Plain
+}
+
+// FileName 返回下载时片段的文件名，不支持的语言类型按纯文本处理
+func FileName(base, langtype string) string {
+	l, ok := Lookup(langtype)
+	if !ok {
+		return base + ".txt"
+	}
+	return base + l.Extension()
+}
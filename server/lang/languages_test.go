@@ -0,0 +1,93 @@
+package lang
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		langtype string
+		want     string
+		ok       bool
+	}{
+		{"", Plain, true},
+		{"  ", Plain, true},
+		{"go", "go", true},
+		{"Go", "go", true},
+		{"golang", "go", true},
+		{".go", "go", true},
+		{" GOLANG ", "go", true},
+		{"py", "python", true},
+		{"yml", "yaml", true},
+		{"c++", "cpp", true},
+		{"h", "c", true},
+		{"txt", Plain, true},
+		{"brainfuck", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := Normalize(tt.langtype)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("Normalize(%q) = %q, %v, want %q, %v", tt.langtype, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestIsUndetermined(t *testing.T) {
+	for langtype, want := range map[string]bool{"": true, "plain": true, "Text": true, "go": false, "unknown": false} {
+		if got := IsUndetermined(langtype); got != want {
+			t.Errorf("IsUndetermined(%q) = %v, want %v", langtype, got, want)
+		}
+	}
+}
+
+func TestFileName(t *testing.T) {
+	tests := []struct {
+		langtype string
+		want     string
+	}{
+		{"go", "snippet-1.go"},
+		{"cpp", "snippet-1.cpp"},
+		{"plain", "snippet-1.txt"},
+		{"unknown", "snippet-1.txt"},
+	}
+	for _, tt := range tests {
+		if got := FileName("snippet-1", tt.langtype); got != tt.want {
+			t.Errorf("FileName(%q) = %q, want %q", tt.langtype, got, tt.want)
+		}
+	}
+}
+
+func TestLanguageRegistry(t *testing.T) {
+	list := Languages()
+	if list[0].ID != Plain {
+		t.Errorf("first language = %q, want %q", list[0].ID, Plain)
+	}
+	if !slices.IsSortedFunc(list[1:], func(a, b Language) int { return strings.Compare(a.ID, b.ID) }) {
+		t.Error("languages are not sorted by ID")
+	}
+	names := make(map[string]string)
+	for _, l := range list {
+		for _, name := range append([]string{l.ID}, l.Aliases...) {
+			if name != strings.ToLower(name) {
+				t.Errorf("%s: name %q is not lowercase", l.ID, name)
+			}
+			if other, ok := names[name]; ok {
+				t.Errorf("name %q is used by both %s and %s", name, other, l.ID)
+			}
+			names[name] = l.ID
+		}
+	}
+	// 自动识别只能返回注册表中的语言类型
+	for langtype := range languageFeatures {
+		if _, ok := Lookup(langtype); !ok {
+			t.Errorf("languageFeatures contains unregistered language %q", langtype)
+		}
+	}
+	for _, langtype := range interpreters {
+		if _, ok := Lookup(langtype); !ok {
+			t.Errorf("interpreters contains unregistered language %q", langtype)
+		}
+	}
+}
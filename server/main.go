@@ -2,24 +2,37 @@ package main
 
 import (
 	"context"
+	"flag"
 	"net/http"
 	"os"
 	"os/signal"
 	"runtime"
 	"syscall"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	log "github.com/sirupsen/logrus"
-	"github.com/spf13/viper"
 
+	"paste.org.cn/paste/server/cache"
 	"paste.org.cn/paste/server/db"
 	"paste.org.cn/paste/server/middleware"
 	"paste.org.cn/paste/server/router"
 	"paste.org.cn/paste/server/storage"
+	"paste.org.cn/paste/server/tracing"
 	"paste.org.cn/paste/server/util"
 )
 
 func main() {
+	// 解析命令行参数，--config 指定配置文件路径
+	configFile := flag.String("config", "", "配置文件路径，默认在当前目录查找 config.yaml")
+	flag.Usage = usage
+	flag.Parse()
+
+	// 执行子命令，例如 config check
+	if args := flag.Args(); len(args) > 0 {
+		os.Exit(runCommand(args, *configFile))
+	}
+
 	// 设置go运行时使用所有的CPU核心，以提高并发能力
 	runtime.GOMAXPROCS(runtime.NumCPU())
 
@@ -28,26 +41,69 @@ func main() {
 	defer cancel()
 
 	// 加载配置文件
-	util.LoadConfig("config")
+	cfg, err := util.LoadConfig(*configFile)
+	if err != nil {
+		log.Fatalf("Fatal error config: %v", err)
+	}
+
+	// 初始化链路追踪
+	shutdownTracing, err := tracing.Init(ctx, cfg.Trace)
+	if err != nil {
+		log.Fatalf("init tracing failed: %+v", err)
+	}
+	defer func() {
+		if err := shutdownTracing(context.Background()); err != nil {
+			log.Errorf("failed to shutdown tracing: %v", err)
+		}
+	}()
 
 	// 初始化 Limits 配置
 	util.InitializeLimits()
 
+	// 初始化 访问令牌 配置
+	util.InitializeAuth()
+
+	// 初始化 读缓存，分享内容和签名URL共用
+	readCache, err := cache.New(cfg.Cache)
+	if err != nil {
+		log.Errorf("init cache failed: %+v", err)
+		return
+	}
+
 	// 初始化 图片存储 配置
-	storage.InitializeStorage()
+	storage.InitializeStorage(readCache)
 
 	// 注入中间件
 	paste := gin.New()
 	paste.Use(gin.Recovery()) // gin.Recovery 是gin自带中间件，用于捕获panic并返回500错误
-	paste.Use(middleware.LogInfo)
+	paste.Use(middleware.AccessLog) // 统一的结构化访问日志
+	paste.Use(middleware.Metrics)
 	paste.Use(middleware.ReqID)
+	paste.Use(middleware.Trace)
+	paste.Use(middleware.RateLimit) // 按客户端 IP 限制请求速率
+	paste.Use(middleware.Auth)
 
 	// 初始化数据库
-	pasteDB, err := db.NewPaste(ctx, viper.Sub("paste.mgo")) //viper.Sub从全局配置中提取键为“paste.mgo"的部分，并返回一个新的viper实例
+	pasteDB, err := db.NewPaste(ctx, cfg.Paste)
 	if err != nil {
 		log.Errorf("init paste db failed: %+v", err)
 		return
 	}
+	pasteDB = db.NewCachedPaste(pasteDB, readCache, time.Duration(cfg.Cache.TTL)*time.Second)
+
+	// 初始化审计事件存储，复用分享内容的 MongoDB 连接
+	auditDB, err := db.NewAudit(ctx, cfg.Paste.Mgo)
+	if err != nil {
+		log.Errorf("init audit db failed: %+v", err)
+		return
+	}
+
+	// 初始化可续传上传存储
+	uploadDB, err := db.NewUpload(ctx, cfg.Paste.Mgo)
+	if err != nil {
+		log.Errorf("init upload db failed: %+v", err)
+		return
+	}
 
 	// 在程序结束时断开MongoDB连接
 	mongoClient := db.GetMongoClient()
@@ -61,17 +117,35 @@ func main() {
 		}
 	}()
 
+	// 定期清理不再被引用的片段文件、图片对象和过期的可续传上传
+	go db.RunCleaner(ctx, time.Duration(cfg.Cleaner.Interval)*time.Minute,
+		db.CleanTask{Name: "无引用的片段文件", Clean: pasteDB.Clean},
+		storage.BlobCleanTask(pasteDB.Blobs()),
+		storage.UploadCleanTask(uploadDB),
+	)
+
 	// 初始化路由
-	router.Init(paste, pasteDB)
+	router.Init(paste, pasteDB, auditDB, uploadDB)
 
 	// 创建服务器
 	srv := &http.Server{
-		Addr:    util.GetServerHost(viper.GetString("server.host")),
+		Addr:    util.GetServerHost(cfg.Server.Host),
 		Handler: paste,
 	}
 	// 启动服务器
 	go util.RunServer(srv)
 
+	// 监听配置文件变化，并在收到 SIGHUP 信号时重载配置
+	util.WatchConfig()
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			log.Info("收到 SIGHUP 信号，重载配置")
+			_ = util.ReloadConfig()
+		}
+	}()
+
 	// 创建通道，用于接收操作系统信号
 	quit := make(chan os.Signal, 5)
 	// 将指定的信号（SIGINT 和 SIGTERM）转发到通道 quit
@@ -0,0 +1,172 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// 指标名前缀
+const namespace = "paste"
+
+// 单个标签允许的最大取值数量，超出后归入 other，避免用户输入导致指标基数膨胀
+const maxLangtypes = 64
+
+var (
+	// HTTPRequests 按路由、方法和状态码统计的请求数
+	HTTPRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "Total number of HTTP requests by route, method and status.",
+	}, []string{"route", "method", "status"})
+
+	// HTTPDuration 按路由、方法和状态码统计的请求耗时
+	HTTPDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency by route, method and status.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"route", "method", "status"})
+
+	// PastesCreated 创建的分享数量
+	PastesCreated = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "pastes_created_total",
+		Help:      "Total number of pastes created by kind (normal/once) and password protection.",
+	}, []string{"kind", "password"})
+
+	// SnippetsCreated 按语言类型统计创建的片段数量
+	SnippetsCreated = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "snippets_created_total",
+		Help:      "Total number of snippets created by langtype.",
+	}, []string{"langtype"})
+
+	// OnceBurns 一次性分享被读取后销毁的数量
+	OnceBurns = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "once_burns_total",
+		Help:      "Total number of once-read pastes burned after being read.",
+	})
+
+	// MongoErrors 按操作统计的 MongoDB 错误数
+	MongoErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "mongo_errors_total",
+		Help:      "Total number of MongoDB errors by operation.",
+	}, []string{"operation"})
+
+	// ImageBytes 按存储类型统计上传的图片字节数
+	ImageBytes = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "image_uploaded_bytes_total",
+		Help:      "Total bytes of uploaded images by storage type.",
+	}, []string{"storage_type"})
+
+	// ImageUploadDuration 按存储类型统计单张图片的上传耗时
+	ImageUploadDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "image_upload_duration_seconds",
+		Help:      "Latency of storing a single image by storage type.",
+		Buckets:   []float64{0.01, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10},
+	}, []string{"storage_type"})
+
+	// ImageDedupHits 云存储中已存在相同内容、跳过上传的图片数
+	ImageDedupHits = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "image_dedup_hits_total",
+		Help:      "Total number of uploaded images whose content was already stored.",
+	})
+
+	// FileBytes 按存储类型统计上传的图片以外的附件字节数
+	FileBytes = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "file_uploaded_bytes_total",
+		Help:      "Total bytes of uploaded non-image attachments by storage type.",
+	}, []string{"storage_type"})
+
+	// OSSFailures 按操作统计的对象存储失败次数
+	OSSFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "oss_failures_total",
+		Help:      "Total number of object storage failures by operation (upload, download, delete, sign, ping).",
+	}, []string{"operation"})
+
+	// CacheRequests 按缓存和结果统计的缓存查询次数
+	CacheRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "cache_requests_total",
+		Help:      "Total number of cache lookups by cache (paste, signed_url) and result (hit, miss).",
+	}, []string{"cache", "result"})
+)
+
+func init() {
+	prometheus.MustRegister(
+		HTTPRequests,
+		HTTPDuration,
+		PastesCreated,
+		SnippetsCreated,
+		OnceBurns,
+		MongoErrors,
+		ImageBytes,
+		ImageUploadDuration,
+		ImageDedupHits,
+		FileBytes,
+		OSSFailures,
+		CacheRequests,
+	)
+}
+
+// Handler 返回 /metrics 接口的处理器
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+// PasteCreated 记录一次分享创建
+func PasteCreated(once, password bool, langtypes []string) {
+	kind := "normal"
+	if once {
+		kind = "once"
+	}
+	PastesCreated.WithLabelValues(kind, strconv.FormatBool(password)).Inc()
+	for _, langtype := range langtypes {
+		SnippetsCreated.WithLabelValues(langtypeLabel(langtype)).Inc()
+	}
+}
+
+var (
+	langtypesMu sync.Mutex
+	langtypes   = make(map[string]struct{})
+)
+
+// langtypeLabel 规范化语言类型标签，超过 maxLangtypes 种后新的取值归入 other
+func langtypeLabel(langtype string) string {
+	langtype = strings.ToLower(strings.TrimSpace(langtype))
+	if langtype == "" {
+		return "none"
+	}
+
+	langtypesMu.Lock()
+	defer langtypesMu.Unlock()
+	if _, ok := langtypes[langtype]; ok {
+		return langtype
+	}
+	if len(langtypes) >= maxLangtypes || len(langtype) > 32 {
+		return "other"
+	}
+	langtypes[langtype] = struct{}{}
+	return langtype
+}
+
+// CacheLookup 记录一次缓存查询结果
+func CacheLookup(cache string, hit bool) {
+	result := "miss"
+	if hit {
+		result = "hit"
+	}
+	CacheRequests.WithLabelValues(cache, result).Inc()
+}
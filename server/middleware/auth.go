@@ -0,0 +1,66 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"paste.org.cn/paste/server/proto"
+	"paste.org.cn/paste/server/util"
+)
+
+// 中间件，用于识别请求携带的访问令牌
+// 令牌可通过 "Authorization: Bearer <token>" 或 "X-Paste-Token" 请求头传递
+// 认证成功时将 util.Credential 保存到 gin 上下文中，未携带令牌或令牌无效的请求按匿名用户继续处理，
+// 读取公开分享不受过期或拼错的令牌影响；需要认证的操作由 RequireRole 和自定义 key 的校验返回 401
+func Auth(c *gin.Context) {
+	token := c.GetHeader("X-Paste-Token")
+	if auth := c.GetHeader("Authorization"); token == "" && auth != "" {
+		if scheme, value, ok := strings.Cut(auth, " "); ok && strings.EqualFold(scheme, "Bearer") {
+			token = strings.TrimSpace(value)
+		}
+	}
+
+	if token != "" {
+		if cred, ok := util.AuthConfig.Authenticate(token); ok {
+			c.Set(util.USER, cred)
+		} else {
+			_, log := util.EnsureWithLogger(c)
+			log.Warn("访问令牌无效，按匿名用户处理")
+		}
+	}
+	c.Next()
+}
+
+// RequireRole 返回一个中间件，仅允许具有指定角色的认证用户访问
+func RequireRole(role string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		cred, ok := CurrentUser(c)
+		if !ok {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+				"code":    http.StatusUnauthorized,
+				"message": proto.ErrUnauthorized,
+			})
+			return
+		}
+		if cred.Role != role {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"code":    http.StatusForbidden,
+				"message": proto.ErrForbidden,
+			})
+			return
+		}
+		c.Next()
+	}
+}
+
+// CurrentUser 返回当前请求的认证用户
+func CurrentUser(c *gin.Context) (util.Credential, bool) {
+	v, ok := c.Get(util.USER)
+	if !ok {
+		return util.Credential{}, false
+	}
+	cred, ok := v.(util.Credential)
+	return cred, ok
+}
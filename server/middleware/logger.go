@@ -1,42 +1,108 @@
 package middleware
 
 import (
-	"fmt"
+	"io"
+	"net/url"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
 
 	"paste.org.cn/paste/server/util"
 )
 
-// 使用gin框架提供的 LoggerWithFormatter 函数创建的自定义日志中间件,该中间件定义了日志的输出格式
-var LogInfo = gin.LoggerWithFormatter(func(param gin.LogFormatterParams) string {
-	return fmt.Sprintf("%s [%s][%s] %s - \"%s %s %s %d %s \"%s\" %s\"\n",
-		param.TimeStamp.Format("2006/01/02 15:04:05.999999"), // 请求时间，格式为 "年/月/日 时:分:秒.微秒"
-		param.Keys[util.REQID],  // 请求唯一标识符
-		"INFO",   // 日志级别
-		param.ClientIP,  // 客户端 IP 地址
-		param.Method,    // http请求方法
-		param.Path,      // 请求路径
-		param.Request.Proto,   // 请求的协议版本，如“http/1.1"
-		param.StatusCode,      // 响应状态码
-		param.Latency,        // 请求处理的延长时间
-		param.Request.UserAgent(),  // 客户端的 user-agent 信息
-		param.ErrorMessage,  // 错误信息
-	)
-})
+// 访问日志中需要隐藏取值的查询参数
+var sensitiveParams = []string{"password", "token", "access_token", "secret"}
+
+// countingReader 统计请求体实际读取的字节数，用于 Content-Length 未知的请求
+type countingReader struct {
+	io.ReadCloser
+	n int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.n += int64(n)
+	return n, err
+}
+
+// 中间件，请求结束后通过 logrus 为每个请求输出一条结构化的访问日志
+// 日志包含 ReqID 和 TraceID，与 handler 中输出的日志可以通过这两个字段关联
+func AccessLog(c *gin.Context) {
+	start := time.Now()
+	body := &countingReader{ReadCloser: c.Request.Body}
+	if c.Request.Body != nil {
+		c.Request.Body = body
+	}
+
+	c.Next()
+
+	_, log := util.EnsureWithLogger(c)
+	fields := logrus.Fields{
+		"method":     c.Request.Method,
+		"route":      c.FullPath(),
+		"path":       c.Request.URL.Path,
+		"query":      redactQuery(c.Request.URL.Query()),
+		"proto":      c.Request.Proto,
+		"status":     c.Writer.Status(),
+		"latency_ms": float64(time.Since(start).Microseconds()) / 1000,
+		"client_ip":  c.ClientIP(),
+		"user_agent": c.Request.UserAgent(),
+		"bytes_in":   max(body.n, c.Request.ContentLength), // 未读取请求体时以 Content-Length 为准
+		"bytes_out":  max(c.Writer.Size(), 0),
+	}
+	if key := c.GetString(util.PASTEKEY); key != "" {
+		fields["paste_key"] = key
+	}
+	if storageType := c.GetString(util.STORAGETYPE); storageType != "" {
+		fields["storage_type"] = storageType
+	}
+	if cred, ok := CurrentUser(c); ok {
+		fields["user"] = cred.Name
+	}
+	if errs := c.Errors.ByType(gin.ErrorTypePrivate).String(); errs != "" {
+		fields["error"] = errs
+	}
+
+	entry := log.WithFields(fields)
+	switch status := c.Writer.Status(); {
+	case status >= 500:
+		entry.Error("access")
+	case status >= 400:
+		entry.Warn("access")
+	default:
+		entry.Info("access")
+	}
+}
+
+// redactQuery 返回隐藏了敏感参数取值的查询字符串
+func redactQuery(query url.Values) string {
+	if len(query) == 0 {
+		return ""
+	}
+	for name := range query {
+		for _, sensitive := range sensitiveParams {
+			if strings.EqualFold(name, sensitive) {
+				query[name] = []string{"REDACTED"}
+			}
+		}
+	}
+	return query.Encode()
+}
 
 // 中间件，用于为每个请求生成或提取唯一的请求 ID。
 func ReqID(c *gin.Context) {
-    // 从请求头中获取名为 util.REQID 的请求 ID
-    reqid := c.Request.Header.Get(util.REQID)
-    if reqid == "" {
-        // 如果请求头中没有提供请求 ID，则生成一个新的请求 ID
-        reqid = util.GenReqID()
-    }
-    // 将请求 ID 设置到 Gin 的上下文中，供后续处理使用
-    c.Set(util.REQID, reqid)
-    // 将请求 ID 添加到响应头中，方便客户端获取
-    c.Header(util.REQID, reqid)
-    // 继续处理请求的后续流程
-    c.Next()
+	// 从请求头中获取名为 util.REQID 的请求 ID
+	reqid := c.Request.Header.Get(util.REQID)
+	if reqid == "" {
+		// 如果请求头中没有提供请求 ID，则生成一个新的请求 ID
+		reqid = util.GenReqID()
+	}
+	// 将请求 ID 设置到 Gin 的上下文中，供后续处理使用
+	c.Set(util.REQID, reqid)
+	// 将请求 ID 添加到响应头中，方便客户端获取
+	c.Header(util.REQID, reqid)
+	// 继续处理请求的后续流程
+	c.Next()
 }
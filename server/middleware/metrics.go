@@ -0,0 +1,26 @@
+package middleware
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"paste.org.cn/paste/server/metrics"
+)
+
+// 中间件，按路由、方法和状态码统计请求数和耗时
+func Metrics(c *gin.Context) {
+	start := time.Now()
+	c.Next()
+
+	// 使用路由模板而不是实际路径，避免 key 等参数导致指标基数膨胀
+	route := c.FullPath()
+	if route == "" {
+		route = "unmatched"
+	}
+	status := strconv.Itoa(c.Writer.Status())
+
+	metrics.HTTPRequests.WithLabelValues(route, c.Request.Method, status).Inc()
+	metrics.HTTPDuration.WithLabelValues(route, c.Request.Method, status).Observe(time.Since(start).Seconds())
+}
@@ -0,0 +1,110 @@
+package middleware
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"paste.org.cn/paste/server/proto"
+	"paste.org.cn/paste/server/util"
+)
+
+// 空闲的令牌桶定期清理，避免大量不同 IP 导致内存持续增长
+const bucketSweepInterval = time.Minute
+
+// 健康检查和监控指标由负载均衡和 Prometheus 频繁访问，不限制速率
+var rateLimitExempt = map[string]bool{
+	"/health":  true,
+	"/livez":   true,
+	"/readyz":  true,
+	"/metrics": true,
+}
+
+// tokenBucket 单个客户端的令牌桶
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// tokenBuckets 按客户端 IP 保存令牌桶
+type tokenBuckets struct {
+	mu        sync.Mutex
+	buckets   map[string]*tokenBucket
+	lastSweep time.Time
+}
+
+func newTokenBuckets() *tokenBuckets {
+	return &tokenBuckets{buckets: make(map[string]*tokenBucket)}
+}
+
+// take 从 key 的令牌桶中取出一个令牌，令牌不足时返回 false 和需要等待的时间
+func (b *tokenBuckets) take(key string, rate float64, burst int, now time.Time) (bool, time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.sweep(rate, burst, now)
+	bucket, ok := b.buckets[key]
+	if !ok {
+		bucket = &tokenBucket{tokens: float64(burst), last: now}
+		b.buckets[key] = bucket
+	}
+	bucket.tokens = math.Min(float64(burst), bucket.tokens+now.Sub(bucket.last).Seconds()*rate)
+	bucket.last = now
+	if bucket.tokens < 1 {
+		return false, time.Duration((1 - bucket.tokens) / rate * float64(time.Second))
+	}
+	bucket.tokens--
+	return true, 0
+}
+
+// sweep 删除已经补满的令牌桶，补满的桶与新建的桶没有区别
+func (b *tokenBuckets) sweep(rate float64, burst int, now time.Time) {
+	if now.Sub(b.lastSweep) < bucketSweepInterval {
+		return
+	}
+	b.lastSweep = now
+	for key, bucket := range b.buckets {
+		if bucket.tokens+now.Sub(bucket.last).Seconds()*rate >= float64(burst) {
+			delete(b.buckets, key)
+		}
+	}
+}
+
+// reset 清空所有令牌桶，新的速率和突发数立即对所有客户端生效
+func (b *tokenBuckets) reset() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.buckets = make(map[string]*tokenBucket)
+}
+
+var requestBuckets = newTokenBuckets()
+
+func init() {
+	// 速率配置在每次请求时通过 GetConfig 读取，重载后清空令牌桶，避免沿用按旧突发数累积的令牌
+	util.RegisterReloadHook([]string{"rate_limit"}, func(cfg *util.Config) {
+		requestBuckets.reset()
+	})
+}
+
+// 中间件，按客户端 IP 限制请求速率，超过限制时返回 429 和 Retry-After
+func RateLimit(c *gin.Context) {
+	config := util.GetConfig().RateLimit
+	if !config.Enabled || rateLimitExempt[c.Request.URL.Path] {
+		c.Next()
+		return
+	}
+
+	if ok, wait := requestBuckets.take(c.ClientIP(), config.Rate, config.Burst, time.Now()); !ok {
+		c.Header("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{
+			"code":    http.StatusTooManyRequests,
+			"message": proto.ErrTooManyRequests,
+		})
+		return
+	}
+	c.Next()
+}
@@ -0,0 +1,44 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+
+	"paste.org.cn/paste/server/tracing"
+	"paste.org.cn/paste/server/util"
+)
+
+// 中间件，为每个请求创建服务端 span，支持通过 traceparent 请求头延续上游的链路
+// 需要放在 ReqID 中间件之后，以便将请求 ID 记录到 span 中
+func Trace(c *gin.Context) {
+	route := c.FullPath()
+	if route == "" {
+		route = "unmatched"
+	}
+
+	ctx, span := tracing.StartServer(c.Request.Context(), c.Request.Header,
+		fmt.Sprintf("%s %s", c.Request.Method, route),
+		attribute.String("http.request.method", c.Request.Method),
+		attribute.String("http.route", route),
+		attribute.String("url.path", c.Request.URL.Path),
+		attribute.String("client.address", c.ClientIP()),
+		attribute.String("paste.reqid", c.GetString(util.REQID)),
+	)
+	defer span.End()
+
+	// 保存到请求上下文和 gin 上下文中，handler 派生出的 context 都能找到该 span
+	c.Request = c.Request.WithContext(ctx)
+	c.Set(tracing.SPAN, span)
+
+	c.Next()
+
+	status := c.Writer.Status()
+	span.SetAttributes(attribute.Int("http.response.status_code", status))
+	if status >= http.StatusInternalServerError {
+		span.SetStatus(codes.Error, http.StatusText(status))
+	}
+}
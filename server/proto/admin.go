@@ -0,0 +1,91 @@
+package proto
+
+import (
+	"time"
+
+	"paste.org.cn/paste/server/util"
+)
+
+// AdminPaste 结构体表示管理接口中分享内容的摘要信息，不包含密码和内容本身
+type AdminPaste struct {
+	Key           string    `json:"key"`                 // 唯一标识
+	Title         string    `json:"title"`               // 分享标题
+	Description   string    `json:"description"`         // 分享描述
+	ClientIP      string    `json:"client_ip"`           // 客户端 IP
+	Once          bool      `json:"once"`                // 是否一次性阅读
+	HasPassword   bool      `json:"has_password"`        // 是否设置了密码
+	SnippetsCount int       `json:"snippets_count"`      // 片段数量
+	ImagesCount   int       `json:"images_count"`        // 图片数量
+	ImagesSize    int64     `json:"images_size"`         // 图片总大小（字节）
+	FilesCount    int       `json:"files_count"`         // 图片以外的附件数量
+	FilesSize     int64     `json:"files_size"`          // 图片以外的附件总大小（字节）
+	CreatedAt     time.Time `json:"created_at"`          // 创建时间
+	ExpireAt      time.Time `json:"expire_at,omitempty"` // 过期时间
+}
+
+// AdminListPastesResp 结构体表示管理接口查询分享内容的响应体
+type AdminListPastesResp struct {
+	Code    int          `json:"code"`              // 状态码
+	Total   int64        `json:"total"`             // 满足条件的总数
+	Pastes  []AdminPaste `json:"pastes"`            // 当前页的分享内容
+	Message string       `json:"message,omitempty"` // 服务器返回的消息（可选）
+}
+
+// AdminDeletePastesReq 结构体表示管理接口批量删除的请求体
+// Keys 与 ClientIP/From/To 至少需要提供一个，避免误删全部数据
+type AdminDeletePastesReq struct {
+	Keys     []string `json:"keys"`      // 需要删除的 key 列表
+	ClientIP string   `json:"client_ip"` // 按客户端 IP 删除
+	From     string   `json:"from"`      // 创建时间起始（RFC3339 或 2006-01-02）
+	To       string   `json:"to"`        // 创建时间结束（RFC3339 或 2006-01-02）
+}
+
+// AdminDeletePastesResp 结构体表示管理接口批量删除的响应体
+type AdminDeletePastesResp struct {
+	Code    int    `json:"code"`              // 状态码
+	Deleted int64  `json:"deleted"`           // 实际删除的数量
+	Message string `json:"message,omitempty"` // 服务器返回的消息（可选）
+}
+
+// StorageUsage 结构体表示某种存储类型的使用情况
+type StorageUsage struct {
+	StorageType string `json:"storage_type"` // 存储类型：base64, cloud
+	Count       int64  `json:"count"`        // 图片数量
+	Bytes       int64  `json:"bytes"`        // 图片总大小（字节）
+}
+
+// AdminStatsResp 结构体表示管理接口存储统计的响应体
+type AdminStatsResp struct {
+	Code      int            `json:"code"`              // 状态码
+	Pastes    int64          `json:"pastes"`            // 分享总数
+	Once      int64          `json:"once"`              // 一次性分享数量
+	Protected int64          `json:"protected"`         // 设置了密码的分享数量
+	Storage   []StorageUsage `json:"storage"`           // 按存储类型统计的图片用量
+	Message   string         `json:"message,omitempty"` // 服务器返回的消息（可选）
+}
+
+// AdminLimitsResp 结构体表示管理接口查看当前生效限制配置的响应体
+type AdminLimitsResp struct {
+	Code    int         `json:"code"`              // 状态码
+	Limits  util.Limits `json:"limits"`            // 当前生效的限制配置
+	Message string      `json:"message,omitempty"` // 服务器返回的消息（可选）
+}
+
+// AuditEvent 结构体表示管理接口中的一条审计事件
+type AuditEvent struct {
+	Type      string    `json:"type"`             // 事件类型：created, read, burned, deleted, reported
+	Key       string    `json:"key"`              // 分享 key
+	ClientIP  string    `json:"client_ip"`        // 客户端 IP
+	User      string    `json:"user,omitempty"`   // 认证用户（访问令牌名称）
+	ReqID     string    `json:"reqid"`            // 请求 ID
+	Detail    string    `json:"detail,omitempty"` // 附加信息，例如举报原因
+	Timestamp time.Time `json:"timestamp"`        // 发生时间
+}
+
+// AdminListAuditResp 结构体表示管理接口查询审计事件的响应体
+type AdminListAuditResp struct {
+	Code    int          `json:"code"`              // 状态码
+	Total   int64        `json:"total"`             // 满足条件的总数
+	Events  []AuditEvent `json:"events"`            // 当前页的审计事件
+	Message string       `json:"message,omitempty"` // 服务器返回的消息（可选）
+}
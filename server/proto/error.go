@@ -10,6 +10,22 @@ const (
 	ErrGetPasteFailed  = "failed to retrieve pasted content"
 	ErrWrongPassword   = "incorrect password"
 	ErrContentExpired  = "the requested content has expired"
-	ErrInvalidFileType = "invalid file type, only images are allowed"
+	ErrInvalidFileType = "invalid file type, only png, jpeg, gif and webp images are allowed"
+	ErrAttachmentType  = "invalid attachment type, only images, pdf, zip, gzip, tar, har, json, log and txt files are allowed"
 	ErrUploadFailed    = "failed to upload file"
+	ErrUploadNotFound  = "upload does not exist, is incomplete or has expired"
+	ErrUploadChunkSize = "each upload chunk must be %d-%d MB, only the last chunk may be smaller"
+	ErrUnauthorized    = "authentication required or invalid token"
+	ErrForbidden       = "permission denied"
+	ErrNotFound        = "the requested content does not exist"
+	ErrQueryFailed     = "failed to query content"
+	ErrDeleteFailed    = "failed to delete content"
+	ErrInvalidKey      = "invalid key, only 4-64 letters, digits, '-' and '_' are allowed"
+	ErrKeyReserved     = "the key is reserved"
+	ErrKeyExists       = "the key is already in use"
+	ErrInvalidLangtype = "unsupported langtype, see GET /v1/languages"
+	ErrInvalidSnippet  = "invalid snippet, the paste has no such snippet"
+	ErrInvalidLines    = "invalid lines, expected N or N-M within the snippet"
+	ErrEmbedForbidden  = "one-time and password-protected pastes cannot be embedded"
+	ErrTooManyRequests = "too many requests, please retry later"
 )
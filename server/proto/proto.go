@@ -3,42 +3,168 @@ package proto
 // Snippet 结构体表示片段类型
 type Snippet struct {
 	Langtype string `json:"langtype" bson:"langtype"` // 代码/文本（如 "go", "python", "text"，"markdown"等）
-	Content  string `json:"content" bson:"content"`   // 片段内容，最大支持十万个字符
+	Content  string `json:"content" bson:"content"`   // 片段内容
+	// 创建时 Langtype 为空或纯文本会自动识别语言，识别结果写入 Langtype
+	Detected   bool    `json:"detected,omitempty" bson:"detected,omitempty"`     // Langtype 是否为自动识别的结果
+	Confidence float64 `json:"confidence,omitempty" bson:"confidence,omitempty"` // 自动识别的置信度，0 到 1
+	// 存储相关字段，由 db 层在写入时压缩或转存，读取时还原为 Content
+	Encoding string `json:"-" bson:"encoding,omitempty"` // 压缩算法：zstd, gzip，为空表示未压缩
+	Data     []byte `json:"-" bson:"data,omitempty"`     // 压缩后的内容
+	FileID   string `json:"-" bson:"file_id,omitempty"`  // 转存到 GridFS 的文件 ID，此时 Data 为空
 }
 
-// ImageFile 结构体表示图片类型
-type ImageFile struct {
+// 附件类型
+const (
+	AttachmentKindImage = "image" // 图片，会生成缩略图并返回宽高
+	AttachmentKindFile  = "file"  // 其他文件，例如 pdf、har、日志压缩包，按原样保存
+)
+
+// Attachment 结构体表示分享中的附件，图片和其他文件使用相同的存储方式
+type Attachment struct {
+	Kind          string `json:"kind" bson:"kind,omitempty"`           // 附件类型：image, file，旧数据为空表示图片
+	Name          string `json:"name,omitempty" bson:"name,omitempty"` // 客户端上传时的文件名，下载时使用
 	StorageType   string `json:"storage_type" bson:"storage_type"`     // 存储类型：base64, cloud
-	Filename      string `json:"filename" bson:"filename"`             // 图片原始文件名
+	Filename      string `json:"filename" bson:"filename"`             // 生成的唯一文件名
 	Size          int64  `json:"size" bson:"size"`                     // 文件大小（字节）
 	ContentType   string `json:"content_type" bson:"content_type"`     // 文件MIME类型
-	Base64Content string `json:"base64_content" bson:"base64_content"` // Base64编码的图片内容
+	Base64Content string `json:"base64_content" bson:"base64_content"` // Base64编码的文件内容
+	Width         int    `json:"width,omitempty" bson:"width,omitempty"`   // 宽度（像素），仅图片
+	Height        int    `json:"height,omitempty" bson:"height,omitempty"` // 高度（像素），仅图片
+	Hash          string `json:"hash,omitempty" bson:"hash,omitempty"`     // 内容的 SHA-256 哈希，云存储按哈希去重
 	// 云存储相关字段
 	ObjectKey string `json:"-" bson:"object_key"`    				   // 对象存储中的唯一标识符
 	URL       string `json:"url" bson:"-"` 							   // 文件访问URL路径
+	// 下载接口的路径，响应头带 Content-Disposition: attachment，一次性分享没有
+	DownloadURL string `json:"download_url,omitempty" bson:"-"`
+	// 缩略图，原图不超过缩略图尺寸或不是图片时为空
+	Thumbnail *ImageVariant `json:"thumbnail,omitempty" bson:"thumbnail,omitempty"`
+}
+
+// IsImage 判断附件是否为图片
+func (a Attachment) IsImage() bool {
+	return a.Kind == "" || a.Kind == AttachmentKindImage
+}
+
+// ImageVariant 结构体表示图片的其他版本，例如缩略图，存储方式与原图相同
+type ImageVariant struct {
+	Size          int64  `json:"size" bson:"size"`                                         // 文件大小（字节）
+	ContentType   string `json:"content_type" bson:"content_type"`                         // 文件MIME类型
+	Width         int    `json:"width" bson:"width"`                                       // 宽度（像素）
+	Height        int    `json:"height" bson:"height"`                                     // 高度（像素）
+	Hash          string `json:"hash,omitempty" bson:"hash,omitempty"`                     // 内容的 SHA-256 哈希
+	Base64Content string `json:"base64_content,omitempty" bson:"base64_content,omitempty"` // Base64编码的图片内容
+	ObjectKey     string `json:"-" bson:"object_key,omitempty"`                            // 对象存储中的唯一标识符
+	URL           string `json:"url,omitempty" bson:"-"`                                   // 文件访问URL路径
 }
 
 // PostPasteReq 结构体表示创建分享请求的请求体
 type PostPasteReq struct {
-	Title       string      `form:"title" json:"title"`                             // 分享标题
-	Description string      `form:"description" json:"description"`                 // 分享描述
-	Snippets    []Snippet   `form:"-" json:"snippets"`                              // 多段代码内容，前后端都需要限制片段字符内容长度和数量
-	Images      []ImageFile `form:"-" json:"images,omitempty"`                      // 多张截图分享内容，前后端需要限制图片大小和数量（10M,5张）
-	Password    string      `form:"password,omitempty" json:"password,omitempty"`   // 访问分享内容的可选密码（omitempty 表示如果为空则不序列化）
-	ExpireAt    int64       `form:"expire_at,omitempty" json:"expire_at,omitempty"` // 过期时间，单位为小时（可选字段）
+	Key         string       `form:"key" json:"key,omitempty"`                       // 自定义 key，仅认证用户可用，为空时随机生成
+	Title       string       `form:"title" json:"title"`                             // 分享标题
+	Description string       `form:"description" json:"description"`                 // 分享描述
+	Snippets    []Snippet    `form:"-" json:"snippets"`                              // 多段代码内容，前后端都需要限制片段字符内容长度和数量
+	Attachments []Attachment `form:"-" json:"attachments,omitempty"`                 // 截图和其他附件，前后端需要限制大小和数量
+	Uploads     []string     `form:"uploads" json:"uploads,omitempty"`               // 引用已完成的可续传上传 ID，按识别出的类型计入图片或附件数量限制
+	Password    string       `form:"password,omitempty" json:"password,omitempty"`   // 访问分享内容的可选密码（omitempty 表示如果为空则不序列化）
+	ExpireAt    int64        `form:"expire_at,omitempty" json:"expire_at,omitempty"` // 过期时间，单位为小时（可选字段）
+}
+
+// UploadResp 结构体表示可续传上传接口的错误响应体，成功时按 tus 协议只返回响应头
+type UploadResp struct {
+	Code    int    `json:"code"`              // 状态码
+	Message string `json:"message,omitempty"` // 服务器返回的消息（可选）
+}
+
+// AttachmentResp 结构体表示下载附件接口的错误响应体，成功时直接返回文件内容
+type AttachmentResp struct {
+	Code    int    `json:"code"`              // 状态码
+	Message string `json:"message,omitempty"` // 服务器返回的消息（可选）
 }
 
 // PostPasteResp 结构体表示创建分享请求的响应体
 type PostPasteResp struct {
 	Code    int    `json:"code"`              // 状态码
 	Key     string `json:"key"`               // 分享内容的唯一标识符
+	URL     string `json:"url,omitempty"`     // 分享链接，使用 server.public_url 生成
 	Message string `json:"message,omitempty"` // 服务器返回的消息（可选）
 }
 
+// QRCodeResp 结构体表示分享二维码接口的错误响应体，成功时直接返回 PNG 图片
+type QRCodeResp struct {
+	Code    int    `json:"code"`              // 状态码
+	Message string `json:"message,omitempty"` // 服务器返回的消息（可选）
+}
+
+// OEmbedResp 结构体表示 oEmbed 接口的响应体，字段含义见 https://oembed.com
+type OEmbedResp struct {
+	Code            int    `json:"code"`                       // 状态码
+	Message         string `json:"message,omitempty"`          // 服务器返回的消息（可选）
+	Version         string `json:"version,omitempty"`          // oEmbed 版本，固定为 1.0
+	Type            string `json:"type,omitempty"`             // 资源类型
+	Title           string `json:"title,omitempty"`            // 分享标题
+	ProviderName    string `json:"provider_name,omitempty"`    // 服务名称
+	ProviderURL     string `json:"provider_url,omitempty"`     // 服务地址
+	CacheAge        int    `json:"cache_age,omitempty"`        // 建议的缓存时间（秒）
+	ThumbnailURL    string `json:"thumbnail_url,omitempty"`    // 第一张图片的地址，一次性分享和有密码的分享没有
+	ThumbnailWidth  int    `json:"thumbnail_width,omitempty"`  // 图片宽度（像素）
+	ThumbnailHeight int    `json:"thumbnail_height,omitempty"` // 图片高度（像素）
+}
+
+// Language 结构体表示一种支持的语言类型
+type Language struct {
+	ID         string   `json:"id"`                   // 创建分享时使用的 langtype
+	Name       string   `json:"name"`                 // 显示名称
+	Aliases    []string `json:"aliases,omitempty"`    // 也可以使用的别名，保存时转换为 id
+	Extensions []string `json:"extensions,omitempty"` // 文件扩展名
+	MIMEType   string   `json:"mime_type"`            // 下载时使用的 MIME 类型
+}
+
+// LanguagesResp 结构体表示支持的语言类型列表的响应体
+type LanguagesResp struct {
+	Code      int        `json:"code"`                // 状态码
+	Languages []Language `json:"languages,omitempty"` // 支持的语言类型，纯文本在最前
+	Message   string     `json:"message,omitempty"`   // 服务器返回的消息（可选）
+}
+
 // GetPasteResp 结构体表示获取分享请求的响应体
 type GetPasteResp struct {
-	Code     int         `json:"code"`              // 状态码
-	Snippets []Snippet   `json:"snippets"`          // 返回多个片段
-	Images   []ImageFile `json:"images,omitempty"`  // 返回多张图片 (可选)
-	Message  string      `json:"message,omitempty"` // 服务器返回的消息（可选）
+	Code        int            `json:"code"`                  // 状态码
+	Snippets    []Snippet      `json:"snippets"`              // 返回多个片段
+	Images      []Attachment   `json:"images,omitempty"`      // 返回多张图片 (可选)
+	Attachments []Attachment   `json:"attachments,omitempty"` // 返回图片以外的附件 (可选)
+	Selection   *LineSelection `json:"selection,omitempty"`   // 通过 snippet 或 lines 参数选择时返回选中的片段和行号 (可选)
+	Message     string         `json:"message,omitempty"`     // 服务器返回的消息（可选）
+}
+
+// LineSelection 结构体表示通过 snippet 和 lines 参数选择的片段和行范围
+type LineSelection struct {
+	Snippet    int `json:"snippet"`     // 片段序号，从 1 开始
+	StartLine  int `json:"start_line"`  // 返回内容的起始行号，从 1 开始
+	EndLine    int `json:"end_line"`    // 返回内容的结束行号（包含）
+	TotalLines int `json:"total_lines"` // 片段的总行数
+}
+
+// ReportPasteReq 结构体表示举报分享请求的请求体
+type ReportPasteReq struct {
+	Reason string `json:"reason"` // 举报原因（可选）
+}
+
+// ReportPasteResp 结构体表示举报分享请求的响应体
+type ReportPasteResp struct {
+	Code    int    `json:"code"`              // 状态码
+	Message string `json:"message,omitempty"` // 服务器返回的消息（可选）
+}
+
+// DependencyStatus 结构体表示就绪检查中单个依赖的状态
+type DependencyStatus struct {
+	Status    string `json:"status"`          // 检查结果：ok, fail
+	LatencyMs int64  `json:"latency_ms"`      // 检查耗时（毫秒）
+	Error     string `json:"error,omitempty"` // 失败原因（可选）
+}
+
+// HealthResp 结构体表示存活与就绪检查的响应体
+type HealthResp struct {
+	Code         int                         `json:"code"`                   // 状态码
+	Status       string                      `json:"status"`                 // 总体状态：ok, fail, shutting_down
+	Dependencies map[string]DependencyStatus `json:"dependencies,omitempty"` // 各依赖的检查结果（仅就绪检查）
 }
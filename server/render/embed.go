@@ -0,0 +1,62 @@
+package render
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"html/template"
+	"io"
+)
+
+// 嵌入页面中唯一的脚本：向父页面上报内容高度，embed.js 据此调整 iframe 的高度
+const embedScript = `(function(){function post(){parent.postMessage({type:"paste-embed-height",height:document.documentElement.scrollHeight},"*")}addEventListener("load",post);addEventListener("resize",post)})();`
+
+// EmbedScriptHash 是嵌入页面脚本的 CSP 哈希来源，例如 'sha256-...'，页面只允许执行该脚本
+var EmbedScriptHash = func() string {
+	sum := sha256.Sum256([]byte(embedScript))
+	return "'sha256-" + base64.StdEncoding.EncodeToString(sum[:]) + "'"
+}()
+
+// Embed 表示嵌入 iframe 的分享页面需要的数据
+type Embed struct {
+	Title    string
+	URL      string       // 分享链接，在新窗口中打开
+	CSS      template.CSS // 高亮主题的样式表
+	Snippets []Snippet
+}
+
+// 嵌入页面去掉标题和主题切换，每个片段带一个简短的标题栏
+var embedTemplate = template.Must(template.New("embed").Parse(`<!DOCTYPE html>
+<html lang="zh-CN">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta name="robots" content="noindex">
+<title>{{if .Title}}{{.Title}}{{else}}Paste{{end}}</title>
+<style>
+body { margin: 0; font-family: -apple-system, "Segoe UI", Helvetica, Arial, sans-serif; }
+section + section { border-top: 1px solid #ddd; }
+.header { display: flex; justify-content: space-between; padding: 6px 10px; background: #f6f8fa; border-bottom: 1px solid #ddd; color: #666; font-size: 13px; }
+.header a { color: #0366d6; text-decoration: none; }
+.chroma { overflow-x: auto; font-size: 13px; margin: 0; }
+.chroma .lnt a, .chroma .ln a { color: inherit; text-decoration: none; }
+.markdown { padding: 0 10px; }
+.markdown img { max-width: 100%; }
+{{.CSS}}
+</style>
+</head>
+<body>
+{{range .Snippets}}
+<section id="{{.ID}}">
+<div class="header"><span>{{if $.Title}}{{$.Title}} · {{end}}#{{.ID}} {{.Langtype}}</span><a href="{{$.URL}}" target="_blank" rel="noopener">在 Paste 中查看</a></div>
+{{if .Markdown}}<div class="markdown">{{.HTML}}</div>{{else}}{{.HTML}}{{end}}
+</section>
+{{end}}
+<script>` + embedScript + `</script>
+</body>
+</html>
+`))
+
+// Write 将嵌入页面写入 w
+func (e Embed) Write(w io.Writer) error {
+	return embedTemplate.Execute(w, e)
+}
@@ -0,0 +1,71 @@
+package render
+
+import (
+	"html/template"
+	"io"
+)
+
+// Link 表示页面中的一个附件链接
+type Link struct {
+	Name string // 显示的文件名
+	URL  string // 下载地址
+	Size int64  // 文件大小（字节）
+}
+
+// Page 表示渲染分享页面需要的数据
+type Page struct {
+	Title       string
+	Description string
+	Theme       string       // 当前使用的主题
+	Themes      []string     // 可选的主题
+	Password    string       // 访问时提供的密码，切换主题时需要一起提交
+	CSS         template.CSS // 高亮主题的样式表
+	Snippets    []Snippet
+	Attachments []Link
+}
+
+// 页面不依赖 JavaScript，切换主题通过 GET 表单提交
+var pageTemplate = template.Must(template.New("page").Parse(`<!DOCTYPE html>
+<html lang="zh-CN">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{if .Title}}{{.Title}}{{else}}Paste{{end}}</title>
+<style>
+body { margin: 0 auto; max-width: 960px; padding: 16px; font-family: -apple-system, "Segoe UI", Helvetica, Arial, sans-serif; }
+section { margin: 24px 0; }
+.langtype { color: #666; font-size: 14px; }
+.chroma { overflow-x: auto; font-size: 14px; }
+.chroma .lnt a, .chroma .ln a { color: inherit; text-decoration: none; }
+.markdown img { max-width: 100%; }
+{{.CSS}}
+</style>
+</head>
+<body>
+{{if .Title}}<h1>{{.Title}}</h1>{{end}}
+{{if .Description}}<p>{{.Description}}</p>{{end}}
+<form method="get">
+<label>主题 <select name="theme">{{range .Themes}}<option{{if eq . $.Theme}} selected{{end}}>{{.}}</option>{{end}}</select></label>
+{{if .Password}}<input type="hidden" name="password" value="{{.Password}}">{{end}}
+<button type="submit">切换</button>
+</form>
+{{range .Snippets}}
+<section id="{{.ID}}">
+<div class="langtype"><a href="#{{.ID}}">#{{.ID}}</a> {{.Langtype}}</div>
+{{if .Markdown}}<div class="markdown">{{.HTML}}</div>{{else}}{{.HTML}}{{end}}
+</section>
+{{end}}
+{{if .Attachments}}
+<section id="attachments">
+<h2>附件</h2>
+<ul>{{range .Attachments}}<li><a href="{{.URL}}">{{.Name}}</a> ({{.Size}} bytes)</li>{{end}}</ul>
+</section>
+{{end}}
+</body>
+</html>
+`))
+
+// Write 将分享页面写入 w
+func (p Page) Write(w io.Writer) error {
+	return pageTemplate.Execute(w, p)
+}
@@ -0,0 +1,56 @@
+package render
+
+import (
+	"html/template"
+	"io"
+)
+
+// Preview 表示链接预览页面需要的数据，聊天工具展开链接时读取其中的 Open Graph 标签
+type Preview struct {
+	SiteName    string
+	Title       string
+	Description string // og:description，不超过几百个字符
+	URL         string // 分享链接，页面会跳转到该地址
+	OEmbedURL   string // oEmbed 接口地址，供支持自动发现的客户端使用
+	Excerpt     string // 第一个片段的前几行，为空时不显示
+	Image       string // 第一张图片（或缩略图）的绝对地址，为空时不输出 og:image
+	ImageWidth  int
+	ImageHeight int
+}
+
+// 预览页面只给爬虫读取标签，浏览器打开时跳转到分享链接
+var previewTemplate = template.Must(template.New("preview").Parse(`<!DOCTYPE html>
+<html lang="zh-CN">
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<meta name="robots" content="noindex">
+<meta name="description" content="{{.Description}}">
+<meta property="og:site_name" content="{{.SiteName}}">
+<meta property="og:type" content="website">
+<meta property="og:title" content="{{.Title}}">
+<meta property="og:description" content="{{.Description}}">
+<meta property="og:url" content="{{.URL}}">
+{{if .Image}}<meta property="og:image" content="{{.Image}}">
+{{if .ImageWidth}}<meta property="og:image:width" content="{{.ImageWidth}}">
+<meta property="og:image:height" content="{{.ImageHeight}}">
+{{end}}<meta name="twitter:card" content="summary_large_image">
+{{else}}<meta name="twitter:card" content="summary">
+{{end}}<meta name="twitter:title" content="{{.Title}}">
+<meta name="twitter:description" content="{{.Description}}">
+<link rel="canonical" href="{{.URL}}">
+<link rel="alternate" type="application/json+oembed" href="{{.OEmbedURL}}" title="{{.Title}}">
+<meta http-equiv="refresh" content="0; url={{.URL}}">
+</head>
+<body>
+<h1>{{.Title}}</h1>
+{{if .Excerpt}}<pre>{{.Excerpt}}</pre>{{end}}
+<p><a href="{{.URL}}">{{.URL}}</a></p>
+</body>
+</html>
+`))
+
+// Write 将预览页面写入 w
+func (p Preview) Write(w io.Writer) error {
+	return previewTemplate.Execute(w, p)
+}
@@ -0,0 +1,143 @@
+package render
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"html/template"
+	"slices"
+	"strings"
+
+	"github.com/alecthomas/chroma/v2"
+	chromahtml "github.com/alecthomas/chroma/v2/formatters/html"
+	"github.com/alecthomas/chroma/v2/lexers"
+	"github.com/alecthomas/chroma/v2/styles"
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+)
+
+// 默认的高亮主题
+const DefaultTheme = "github"
+
+// 按 Markdown 渲染的片段语言类型
+var markdownLangtypes = []string{"markdown", "md"}
+
+var ErrUnknownTheme = errors.New("unknown theme")
+
+var (
+	// GFM 扩展支持表格、删除线、任务列表和自动链接，不允许原始 HTML，渲染结果再经过 sanitizer 过滤
+	markdown = goldmark.New(goldmark.WithExtensions(extension.GFM))
+	// 只允许用户内容常用的标签和属性，移除脚本、事件属性和危险的链接协议
+	sanitizer = bluemonday.UGCPolicy()
+)
+
+// Snippet 表示渲染后的一个片段
+type Snippet struct {
+	ID       string        // 片段的锚点，例如 s1，行号锚点为 s1-L3
+	Langtype string        // 片段的语言类型
+	Markdown bool          // 是否按 Markdown 渲染
+	HTML     template.HTML // 渲染后的 HTML
+}
+
+// LineRange 表示片段中的行范围，行号从 1 开始，包含 Start 和 End，零值表示全部行
+type LineRange struct {
+	Start int
+	End   int
+}
+
+// IsZero 判断是否为全部行
+func (r LineRange) IsZero() bool {
+	return r.Start == 0 && r.End == 0
+}
+
+// Themes 返回所有可用的高亮主题名称
+func Themes() []string {
+	names := styles.Names()
+	slices.Sort(names)
+	return names
+}
+
+// Renderer 使用同一个高亮主题渲染分享的片段
+type Renderer struct {
+	style *chroma.Style
+}
+
+// New 创建使用指定主题的渲染器，主题为空时使用 DefaultTheme，不存在时返回 ErrUnknownTheme
+func New(theme string) (*Renderer, error) {
+	if theme == "" {
+		theme = DefaultTheme
+	}
+	style, ok := styles.Registry[theme]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownTheme, theme)
+	}
+	return &Renderer{style: style}, nil
+}
+
+// newFormatter 创建使用 CSS 类的格式化器，显示行号，lineAnchor 为行号锚点的前缀
+func newFormatter(lineAnchor string, options ...chromahtml.Option) *chromahtml.Formatter {
+	return chromahtml.New(append([]chromahtml.Option{
+		chromahtml.WithClasses(true),
+		chromahtml.WithLineNumbers(true),
+		chromahtml.LineNumbersInTable(true),
+		chromahtml.WithLinkableLineNumbers(true, lineAnchor),
+		chromahtml.TabWidth(4),
+	}, options...)...)
+}
+
+// CSS 返回高亮主题的样式表
+func (r *Renderer) CSS() (template.CSS, error) {
+	var buf bytes.Buffer
+	if err := newFormatter("").WriteCSS(&buf, r.style); err != nil {
+		return "", err
+	}
+	return template.CSS(buf.String()), nil
+}
+
+// Snippet 渲染第 index 个片段（从 1 开始），Markdown 渲染为过滤后的 HTML，其他语言按 langtype 高亮并带行号锚点
+func (r *Renderer) Snippet(index int, langtype, content string) (Snippet, error) {
+	return r.SnippetLines(index, langtype, content, LineRange{})
+}
+
+// SnippetLines 只渲染片段中 lines 范围内的行，行号和锚点与完整片段一致。
+// 整个片段一起分析后再截取，多行注释和字符串中间的行也能正确高亮；指定行范围时 Markdown 按源码高亮
+func (r *Renderer) SnippetLines(index int, langtype, content string, lines LineRange) (Snippet, error) {
+	snippet := Snippet{ID: fmt.Sprintf("s%d", index), Langtype: langtype}
+	if lines.IsZero() && slices.Contains(markdownLangtypes, strings.ToLower(langtype)) {
+		snippet.Markdown = true
+		var buf bytes.Buffer
+		if err := markdown.Convert([]byte(content), &buf); err != nil {
+			return snippet, err
+		}
+		snippet.HTML = template.HTML(sanitizer.SanitizeBytes(buf.Bytes()))
+		return snippet, nil
+	}
+
+	lexer := lexers.Get(langtype)
+	if lexer == nil {
+		lexer = lexers.Fallback
+	}
+	iterator, err := chroma.Coalesce(lexer).Tokenise(nil, content)
+	if err != nil {
+		return snippet, err
+	}
+	var options []chromahtml.Option
+	if !lines.IsZero() {
+		all := chroma.SplitTokensIntoLines(iterator.Tokens())
+		start, end := max(lines.Start, 1), min(lines.End, len(all))
+		var tokens []chroma.Token
+		for _, line := range all[min(start-1, end):end] {
+			tokens = append(tokens, line...)
+		}
+		iterator = chroma.Literator(tokens...)
+		options = append(options, chromahtml.BaseLineNumber(start))
+	}
+	// 行号锚点带上片段 ID，同一页面中多个片段的行号不会冲突
+	var buf bytes.Buffer
+	if err := newFormatter(snippet.ID+"-L", options...).Format(&buf, r.style, iterator); err != nil {
+		return snippet, err
+	}
+	snippet.HTML = template.HTML(buf.String())
+	return snippet, nil
+}
@@ -5,21 +5,66 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"paste.org.cn/paste/server/db"
+	"paste.org.cn/paste/server/metrics"
+	"paste.org.cn/paste/server/middleware"
 	"paste.org.cn/paste/server/service"
+	"paste.org.cn/paste/server/util"
 )
 
 // 注册路由
-func Init(r *gin.Engine, pasteDB db.Paste) {
+func Init(r *gin.Engine, pasteDB db.Paste, auditDB db.Audit, uploadDB db.Upload) {
 	paste := &service.Paste{
-		Paste: pasteDB,
+		Paste:   pasteDB,
+		Audit:   auditDB,
+		Uploads: uploadDB,
 	}
 
 	r.POST("/v1/paste", paste.PostPaste) //创建分享内容
 	r.POST("/v1/paste/once", paste.PostPasteOnce) //创建一次性分享内容
 	r.GET("/v1/paste/:key", paste.GetPaste) //获取分享内容
+	r.POST("/v1/report/:key", paste.ReportPaste) //举报分享内容，不放在 /v1/paste/:key 下以免与 POST /v1/paste/once 冲突
+	r.GET("/v1/paste/:key/attachments/:index", paste.GetAttachment) //下载附件
+	r.GET("/v1/paste/:key/qr.png", paste.GetQRCode) //分享链接的二维码
+	r.GET("/v1/paste/:key/html", paste.GetPasteHTML) //以 HTML 页面返回分享内容
+	r.GET("/v1/paste/:key/raw", paste.GetPasteRaw) //以纯文本返回第一个片段或选中的片段和行
+	r.GET("/v1/paste/:key/archive.zip", paste.GetPasteArchive) //以 zip 压缩包下载所有片段和附件
+	r.GET("/v1/paste/:key/preview", paste.GetPastePreview) //带 Open Graph 标签的链接预览，不会销毁一次性分享
+	r.GET("/v1/paste/:key/embed", paste.GetPasteEmbed) //可嵌入 iframe 的 HTML 页面，支持选择片段和行范围
+	r.GET("/v1/paste/:key/embed.js", paste.GetEmbedJS) //插入嵌入 iframe 的脚本
+	r.GET("/v1/oembed", paste.GetOEmbed) //oEmbed 链接预览
+	r.GET("/v1/languages", paste.GetLanguages) //支持的语言类型
+
+	// tus 可续传上传
+	upload := &service.Upload{Upload: uploadDB}
+	r.OPTIONS("/v1/uploads", upload.Options)   //查询协议支持的版本和扩展
+	r.POST("/v1/uploads", upload.Create)       //创建上传
+	r.HEAD("/v1/uploads/:id", upload.Head)     //查询上传进度
+	r.PATCH("/v1/uploads/:id", upload.Patch)   //从指定偏移量继续上传
+	r.DELETE("/v1/uploads/:id", upload.Delete) //终止上传
+
+	// 管理接口，仅允许 admin 角色访问
+	admin := &service.Admin{
+		Paste: pasteDB,
+		Audit: auditDB,
+	}
+	adminGroup := r.Group("/admin/v1", middleware.RequireRole(util.RoleAdmin))
+	{
+		adminGroup.GET("/pastes", admin.ListPastes)        //按 key/IP/时间范围查询分享内容
+		adminGroup.DELETE("/pastes", admin.DeletePastes)   //批量删除分享内容
+		adminGroup.GET("/stats", admin.Stats)              //存储用量统计
+		adminGroup.GET("/limits", admin.Limits)            //当前生效的限制配置
+		adminGroup.GET("/audit", admin.ListAudit)          //按类型/key/IP/用户/时间范围查询审计事件
+		adminGroup.GET("/audit/export", admin.ExportAudit) //以 JSON Lines 格式导出审计事件
+	}
+
+	// prometheus 指标
+	r.GET("/metrics", gin.WrapH(metrics.Handler()))
 
 	// health check
 	r.Any("/health", func(c *gin.Context) {
 		c.String(http.StatusOK, "paste ok!")
 	})
+	health := &service.Health{}
+	r.GET("/livez", health.Livez)   //存活检查
+	r.GET("/readyz", health.Readyz) //就绪检查，检查 MongoDB 和对象存储
 }
@@ -0,0 +1,299 @@
+package service
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"paste.org.cn/paste/server/db"
+	"paste.org.cn/paste/server/proto"
+	"paste.org.cn/paste/server/util"
+)
+
+// 管理接口分页参数
+const (
+	adminDefaultLimit = 20
+	adminMaxLimit     = 100
+)
+
+type Admin struct {
+	db.Paste
+	Audit db.Audit // 分享生命周期审计事件
+}
+
+// 按条件查询分享内容
+func (a *Admin) ListPastes(c *gin.Context) {
+	var (
+		ctx, log = util.EnsureWithLogger(c)
+		filter   db.PasteFilter
+		err      error
+	)
+
+	if key := c.Query("key"); key != "" {
+		filter.Keys = []string{key}
+	}
+	filter.ClientIP = c.Query("ip")
+	if filter.From, err = parseAdminTime(c.Query("from")); err != nil {
+		log.Errorf("解析 from 参数失败: %+v", err)
+		c.JSON(http.StatusBadRequest, proto.AdminListPastesResp{
+			Code:    http.StatusBadRequest,
+			Message: proto.ErrInvalidArgs,
+		})
+		return
+	}
+	if filter.To, err = parseAdminTime(c.Query("to")); err != nil {
+		log.Errorf("解析 to 参数失败: %+v", err)
+		c.JSON(http.StatusBadRequest, proto.AdminListPastesResp{
+			Code:    http.StatusBadRequest,
+			Message: proto.ErrInvalidArgs,
+		})
+		return
+	}
+
+	filter.Limit = adminDefaultLimit
+	if limit, err := strconv.ParseInt(c.Query("limit"), 10, 64); err == nil && limit > 0 {
+		filter.Limit = min(limit, adminMaxLimit)
+	}
+	if skip, err := strconv.ParseInt(c.Query("skip"), 10, 64); err == nil && skip > 0 {
+		filter.Skip = skip
+	}
+
+	entries, total, err := a.Paste.Find(ctx, filter)
+	if err != nil {
+		log.Errorf("查询分享内容失败: %+v", err)
+		c.JSON(http.StatusInternalServerError, proto.AdminListPastesResp{
+			Code:    http.StatusInternalServerError,
+			Message: proto.ErrQueryFailed,
+		})
+		return
+	}
+
+	pastes := make([]proto.AdminPaste, 0, len(entries))
+	for _, entry := range entries {
+		pastes = append(pastes, toAdminPaste(entry))
+	}
+
+	c.JSON(http.StatusOK, proto.AdminListPastesResp{
+		Code:   http.StatusOK,
+		Total:  total,
+		Pastes: pastes,
+	})
+}
+
+// 批量删除分享内容
+func (a *Admin) DeletePastes(c *gin.Context) {
+	var (
+		ctx, log = util.EnsureWithLogger(c)
+		req      proto.AdminDeletePastesReq
+		filter   db.PasteFilter
+		err      error
+	)
+
+	if err = c.ShouldBindJSON(&req); err != nil {
+		log.Errorf("绑定请求数据失败: %+v", err)
+		c.JSON(http.StatusBadRequest, proto.AdminDeletePastesResp{
+			Code:    http.StatusBadRequest,
+			Message: proto.ErrInvalidArgs,
+		})
+		return
+	}
+
+	filter.Keys = req.Keys
+	filter.ClientIP = req.ClientIP
+	filter.From, err = parseAdminTime(req.From)
+	if err == nil {
+		filter.To, err = parseAdminTime(req.To)
+	}
+	if err != nil || filter.IsEmpty() {
+		log.Errorf("删除条件无效: %+v, err: %v", req, err)
+		c.JSON(http.StatusBadRequest, proto.AdminDeletePastesResp{
+			Code:    http.StatusBadRequest,
+			Message: proto.ErrInvalidArgs,
+		})
+		return
+	}
+
+	keys, err := a.Paste.Delete(ctx, filter)
+	if err != nil {
+		log.Errorf("删除分享内容失败: %+v", err)
+		c.JSON(http.StatusInternalServerError, proto.AdminDeletePastesResp{
+			Code:    http.StatusInternalServerError,
+			Message: proto.ErrDeleteFailed,
+		})
+		return
+	}
+	log.Infof("管理员删除分享内容 %d 条, 条件: %+v", len(keys), req)
+	for _, key := range keys {
+		recordAudit(ctx, c, log, a.Audit, db.AuditDeleted, key, "")
+	}
+
+	c.JSON(http.StatusOK, proto.AdminDeletePastesResp{
+		Code:    http.StatusOK,
+		Deleted: int64(len(keys)),
+	})
+}
+
+// 查看存储用量统计
+func (a *Admin) Stats(c *gin.Context) {
+	ctx, log := util.EnsureWithLogger(c)
+
+	stats, err := a.Paste.Stats(ctx)
+	if err != nil {
+		log.Errorf("统计存储用量失败: %+v", err)
+		c.JSON(http.StatusInternalServerError, proto.AdminStatsResp{
+			Code:    http.StatusInternalServerError,
+			Message: proto.ErrQueryFailed,
+		})
+		return
+	}
+
+	storage := make([]proto.StorageUsage, 0, len(stats.Storage))
+	for _, s := range stats.Storage {
+		storage = append(storage, proto.StorageUsage{
+			StorageType: s.StorageType,
+			Count:       s.Count,
+			Bytes:       s.Bytes,
+		})
+	}
+
+	c.JSON(http.StatusOK, proto.AdminStatsResp{
+		Code:      http.StatusOK,
+		Pastes:    stats.Pastes,
+		Once:      stats.Once,
+		Protected: stats.Protected,
+		Storage:   storage,
+	})
+}
+
+// 查看当前生效的限制配置
+func (a *Admin) Limits(c *gin.Context) {
+	c.JSON(http.StatusOK, proto.AdminLimitsResp{
+		Code:   http.StatusOK,
+		Limits: util.LimitConfig.Snapshot(),
+	})
+}
+
+// 按条件查询审计事件
+func (a *Admin) ListAudit(c *gin.Context) {
+	ctx, log := util.EnsureWithLogger(c)
+
+	filter, err := parseAuditFilter(c)
+	if err != nil {
+		log.Errorf("解析审计查询条件失败: %+v", err)
+		c.JSON(http.StatusBadRequest, proto.AdminListAuditResp{
+			Code:    http.StatusBadRequest,
+			Message: proto.ErrInvalidArgs,
+		})
+		return
+	}
+	filter.Limit = adminDefaultLimit
+	if limit, err := strconv.ParseInt(c.Query("limit"), 10, 64); err == nil && limit > 0 {
+		filter.Limit = min(limit, adminMaxLimit)
+	}
+	if skip, err := strconv.ParseInt(c.Query("skip"), 10, 64); err == nil && skip > 0 {
+		filter.Skip = skip
+	}
+
+	events, total, err := a.Audit.Find(ctx, filter)
+	if err != nil {
+		log.Errorf("查询审计事件失败: %+v", err)
+		c.JSON(http.StatusInternalServerError, proto.AdminListAuditResp{
+			Code:    http.StatusInternalServerError,
+			Message: proto.ErrQueryFailed,
+		})
+		return
+	}
+
+	resp := make([]proto.AuditEvent, 0, len(events))
+	for _, event := range events {
+		resp = append(resp, proto.AuditEvent(event))
+	}
+
+	c.JSON(http.StatusOK, proto.AdminListAuditResp{
+		Code:   http.StatusOK,
+		Total:  total,
+		Events: resp,
+	})
+}
+
+// 以 JSON Lines 格式导出审计事件
+func (a *Admin) ExportAudit(c *gin.Context) {
+	ctx, log := util.EnsureWithLogger(c)
+
+	filter, err := parseAuditFilter(c)
+	if err != nil {
+		log.Errorf("解析审计查询条件失败: %+v", err)
+		c.JSON(http.StatusBadRequest, proto.AdminListAuditResp{
+			Code:    http.StatusBadRequest,
+			Message: proto.ErrInvalidArgs,
+		})
+		return
+	}
+	if limit, err := strconv.ParseInt(c.Query("limit"), 10, 64); err == nil && limit > 0 {
+		filter.Limit = limit
+	}
+
+	filename := "audit-" + time.Now().Format("20060102150405") + ".jsonl"
+	c.Header("Content-Type", "application/x-ndjson")
+	c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+	c.Status(http.StatusOK)
+
+	// 响应头已经写出，导出中途失败只能记录日志
+	count, err := a.Audit.Export(ctx, filter, c.Writer)
+	if err != nil {
+		log.Errorf("导出审计事件失败, 已导出 %d 条: %+v", count, err)
+		return
+	}
+	log.Infof("导出审计事件 %d 条", count)
+}
+
+// parseAuditFilter 从查询参数中解析审计事件的查询条件
+func parseAuditFilter(c *gin.Context) (filter db.AuditFilter, err error) {
+	filter.Type = c.Query("type")
+	filter.Key = c.Query("key")
+	filter.ClientIP = c.Query("ip")
+	filter.User = c.Query("user")
+	if filter.From, err = parseAdminTime(c.Query("from")); err != nil {
+		return
+	}
+	filter.To, err = parseAdminTime(c.Query("to"))
+	return
+}
+
+// toAdminPaste 将数据库记录转换为管理接口摘要
+func toAdminPaste(entry db.PasteEntry) proto.AdminPaste {
+	paste := proto.AdminPaste{
+		Key:           entry.Key,
+		Title:         entry.Title,
+		Description:   entry.Description,
+		ClientIP:      entry.ClientIP,
+		Once:          entry.Once,
+		HasPassword:   entry.Password != "",
+		SnippetsCount: len(entry.Snippets),
+		CreatedAt:     entry.CreatedAt,
+		ExpireAt:      entry.ExpireAt,
+	}
+	for _, attachment := range entry.Attachments {
+		if attachment.IsImage() {
+			paste.ImagesCount++
+			paste.ImagesSize += attachment.Size
+		} else {
+			paste.FilesCount++
+			paste.FilesSize += attachment.Size
+		}
+	}
+	return paste
+}
+
+// parseAdminTime 解析 RFC3339 或 2006-01-02 格式的时间，空字符串返回零值
+func parseAdminTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.ParseInLocation(time.DateOnly, value, time.Local)
+}
@@ -0,0 +1,35 @@
+package service
+
+import (
+	"context"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+
+	"paste.org.cn/paste/server/db"
+	"paste.org.cn/paste/server/middleware"
+	"paste.org.cn/paste/server/util"
+)
+
+// recordAudit 追加一条分享生命周期审计事件
+// 审计写入失败只记录日志，不影响请求本身的处理结果
+func recordAudit(ctx context.Context, c *gin.Context, log *logrus.Entry, audit db.Audit, eventType, key, detail string) {
+	if audit == nil {
+		return
+	}
+
+	event := db.AuditEvent{
+		Type:     eventType,
+		Key:      key,
+		ClientIP: c.ClientIP(),
+		ReqID:    util.GetReqID(ctx),
+		Detail:   detail,
+	}
+	if cred, ok := middleware.CurrentUser(c); ok {
+		event.User = cred.Name
+	}
+
+	if err := audit.Append(ctx, event); err != nil {
+		log.Errorf("写入审计事件失败: %+v, event: %+v", err, event)
+	}
+}
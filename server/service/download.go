@@ -0,0 +1,206 @@
+package service
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"paste.org.cn/paste/server/db"
+	"paste.org.cn/paste/server/lang"
+	"paste.org.cn/paste/server/proto"
+	"paste.org.cn/paste/server/storage"
+	"paste.org.cn/paste/server/util"
+)
+
+// 压缩包中直接存储、不再压缩的附件类型
+var compressedTypes = []string{"application/zip", "application/gzip"}
+
+// contentDisposition 生成带文件名的 Content-Disposition，文件名包含非 ASCII 字符时按 RFC 2231 编码
+func contentDisposition(dispositionType, filename string) string {
+	if disposition := mime.FormatMediaType(dispositionType, map[string]string{"filename": filename}); disposition != "" {
+		return disposition
+	}
+	return dispositionType
+}
+
+// getPasteText 读取分享内容并记录审计事件，失败时返回 text/plain 的错误信息，供不使用 JSON 的接口调用
+func (p *Paste) getPasteText(c *gin.Context, key, password string) (db.PasteEntry, bool) {
+	ctx, log := util.EnsureWithLogger(c)
+	entry, err := p.Paste.Get(ctx, key, password)
+	if err != nil {
+		log.Errorf("获取分享内容失败: %+v", err)
+		switch err.Error() {
+		case proto.ErrWrongPassword:
+			c.String(http.StatusUnauthorized, proto.ErrWrongPassword)
+		case proto.ErrContentExpired:
+			c.String(http.StatusGone, proto.ErrContentExpired)
+		default:
+			c.String(http.StatusNotFound, proto.ErrGetPasteFailed)
+		}
+		return entry, false
+	}
+
+	if entry.Once {
+		recordAudit(ctx, c, log, p.Audit, db.AuditBurned, key, "")
+	} else {
+		recordAudit(ctx, c, log, p.Audit, db.AuditRead, key, "")
+	}
+	return entry, true
+}
+
+// 以纯文本返回分享的第一个片段，支持 snippet 和 lines 参数选择片段和行范围
+func (p *Paste) GetPasteRaw(c *gin.Context) {
+	var (
+		key, password = c.Param("key"), c.Query("password")
+		download      = c.Query("download") != ""
+	)
+	c.Set(util.PASTEKEY, key)
+	c.Header("Cache-Control", "no-store")
+
+	// 读取前先检查参数格式，避免格式错误时销毁一次性分享
+	selection, message := parseSnippetQuery(c)
+	if message != "" {
+		c.String(http.StatusBadRequest, message)
+		return
+	}
+	entry, ok := p.getPasteText(c, key, password)
+	if !ok {
+		return
+	}
+	if len(entry.Snippets) == 0 {
+		c.String(http.StatusNotFound, proto.ErrNotFound)
+		return
+	}
+	// 一次性分享已经销毁，忽略超出范围的选择，与 GET /v1/paste/:key 一致
+	if message := selection.validate(entry.Snippets); message != "" {
+		if !entry.Once {
+			c.String(http.StatusBadRequest, message)
+			return
+		}
+		selection = snippetSelection{}
+	}
+
+	setPasteCacheHeaders(c, entry)
+	if !entry.Once && notModified(c) {
+		c.Status(http.StatusNotModified)
+		return
+	}
+
+	// 默认返回第一个片段，通过 snippet 和 lines 参数可以只返回选中的片段和行
+	snippets, lines := selection.apply(entry.Snippets)
+	snippet := snippets[0]
+	if lines != nil {
+		c.Header("X-Paste-Lines", fmt.Sprintf("%d-%d/%d", lines.StartLine, lines.EndLine, lines.TotalLines))
+	}
+
+	// 在浏览器中直接查看时总是使用 text/plain，下载时使用语言对应的 MIME 类型和扩展名
+	contentType, dispositionType := "text/plain; charset=utf-8", "inline"
+	if download {
+		if language, ok := lang.Lookup(snippet.Langtype); ok {
+			contentType = language.MIMEType + "; charset=utf-8"
+		}
+		dispositionType = "attachment"
+	}
+	c.Header("Content-Disposition", contentDisposition(dispositionType, lang.FileName(key, snippet.Langtype)))
+	c.Header("X-Content-Type-Options", "nosniff")
+	c.Header("Content-Security-Policy", "default-src 'none'; sandbox")
+	c.Data(http.StatusOK, contentType, []byte(snippet.Content))
+}
+
+// 以 zip 压缩包下载分享的所有片段和附件
+func (p *Paste) GetPasteArchive(c *gin.Context) {
+	var (
+		ctx, log      = util.EnsureWithLogger(c)
+		key, password = c.Param("key"), c.Query("password")
+	)
+	c.Set(util.PASTEKEY, key)
+	c.Header("Cache-Control", "no-store")
+
+	entry, ok := p.getPasteText(c, key, password)
+	if !ok {
+		return
+	}
+
+	setPasteCacheHeaders(c, entry)
+	if !entry.Once && notModified(c) {
+		c.Status(http.StatusNotModified)
+		return
+	}
+
+	// 先在内存中生成压缩包，读取附件失败时还可以返回错误状态码，大小受片段和附件的限制约束
+	var buf bytes.Buffer
+	if err := writeArchive(ctx, &buf, entry); err != nil {
+		log.Errorf("生成压缩包失败: %+v", err)
+		c.Header("Cache-Control", "no-store")
+		c.String(http.StatusInternalServerError, proto.ErrGetPasteFailed)
+		return
+	}
+
+	c.Header("Content-Disposition", contentDisposition("attachment", key+".zip"))
+	c.Header("X-Content-Type-Options", "nosniff")
+	c.Data(http.StatusOK, "application/zip", buf.Bytes())
+}
+
+// writeArchive 将片段按语言类型命名为 snippet-1.go 等文件，附件放在 attachments/ 目录，一次性分享不包含附件
+func writeArchive(ctx context.Context, w io.Writer, entry db.PasteEntry) error {
+	zw := zip.NewWriter(w)
+	for i, snippet := range entry.Snippets {
+		f, err := zw.CreateHeader(&zip.FileHeader{
+			Name:     lang.FileName(fmt.Sprintf("snippet-%d", i+1), snippet.Langtype),
+			Method:   zip.Deflate,
+			Modified: entry.CreatedAt,
+		})
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(f, snippet.Content); err != nil {
+			return err
+		}
+	}
+
+	if !entry.Once {
+		for i, attachment := range entry.Attachments {
+			if err := writeArchiveAttachment(ctx, zw, i, attachment, entry.CreatedAt); err != nil {
+				return fmt.Errorf("attachment %d: %w", i, err)
+			}
+		}
+	}
+	return zw.Close()
+}
+
+// writeArchiveAttachment 将第 index 个附件写入压缩包，文件名加上序号避免重名
+func writeArchiveAttachment(ctx context.Context, zw *zip.Writer, index int, attachment proto.Attachment, modified time.Time) error {
+	body, err := storage.OpenAttachment(ctx, attachment)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	name := attachment.Name
+	if name == "" {
+		name = attachment.Filename
+	}
+	// 图片和压缩包已经压缩过，直接存储
+	method := zip.Deflate
+	if attachment.IsImage() || slices.Contains(compressedTypes, attachment.ContentType) {
+		method = zip.Store
+	}
+	f, err := zw.CreateHeader(&zip.FileHeader{
+		Name:     fmt.Sprintf("attachments/%d-%s", index, name),
+		Method:   method,
+		Modified: modified,
+	})
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(f, body)
+	return err
+}
@@ -0,0 +1,200 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"text/template"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"paste.org.cn/paste/server/db"
+	"paste.org.cn/paste/server/proto"
+	"paste.org.cn/paste/server/render"
+	"paste.org.cn/paste/server/util"
+)
+
+// embed.js 在 script 标签的位置插入 iframe，并根据嵌入页面上报的高度调整 iframe 高度
+var embedJSTemplate = template.Must(template.New("embed.js").Funcs(template.FuncMap{
+	"json": func(v any) (string, error) {
+		// json.Marshal 会转义 <、>、&，可以安全地放在 script 中
+		data, err := json.Marshal(v)
+		return string(data), err
+	},
+}).Parse(`(function () {
+  var script = document.currentScript;
+  var iframe = document.createElement("iframe");
+  iframe.src = {{json .URL}};
+  iframe.title = {{json .Title}};
+  iframe.loading = "lazy";
+  iframe.setAttribute("sandbox", "allow-scripts allow-popups allow-popups-to-escape-sandbox");
+  iframe.style.cssText = "display:block;width:100%;height:320px;border:1px solid #ddd;border-radius:4px;";
+  window.addEventListener("message", function (event) {
+    if (event.source === iframe.contentWindow && event.data && event.data.type === "paste-embed-height") {
+      iframe.style.height = Math.ceil(event.data.height) + 2 + "px";
+    }
+  });
+  script.parentNode.insertBefore(iframe, script);
+})();
+`))
+
+// embedContentSecurityPolicy 返回嵌入页面的 CSP，只允许执行上报高度的脚本，并按 embed.frame_ancestors 限制嵌入来源
+func embedContentSecurityPolicy(config util.EmbedConfig) string {
+	return fmt.Sprintf("default-src 'none'; style-src 'unsafe-inline'; img-src 'self' https: data:; script-src %s; frame-ancestors %s",
+		render.EmbedScriptHash, strings.Join(config.FrameAncestors, " "))
+}
+
+// getEmbeddable 读取可以嵌入的分享，不会销毁一次性分享，失败时返回状态码和错误信息
+func (p *Paste) getEmbeddable(c *gin.Context, key string) (db.PasteEntry, int, string) {
+	ctx, log := util.EnsureWithLogger(c)
+	if !util.GetConfig().Embed.Enabled {
+		return db.PasteEntry{}, http.StatusNotFound, proto.ErrNotFound
+	}
+	entry, err := p.Paste.Preview(ctx, key)
+	switch {
+	case errors.Is(err, mongo.ErrNoDocuments):
+		return entry, http.StatusNotFound, proto.ErrNotFound
+	case err != nil && err.Error() == proto.ErrContentExpired:
+		return entry, http.StatusGone, proto.ErrContentExpired
+	case err != nil:
+		log.Errorf("获取分享内容失败: %+v", err)
+		return entry, http.StatusInternalServerError, proto.ErrQueryFailed
+	case entry.Once || entry.Password != "":
+		// 嵌入的页面对所有访问者可见，一次性分享会被第一个访问者销毁，密码会暴露在页面源码中
+		return entry, http.StatusForbidden, proto.ErrEmbedForbidden
+	}
+	return entry, http.StatusOK, ""
+}
+
+// 以可嵌入 iframe 的 HTML 页面返回分享内容，支持 snippet 和 lines 参数选择片段和行范围
+func (p *Paste) GetPasteEmbed(c *gin.Context) {
+	var (
+		ctx, log = util.EnsureWithLogger(c)
+		key      = c.Param("key")
+		theme    = c.DefaultQuery("theme", render.DefaultTheme)
+	)
+	c.Set(util.PASTEKEY, key)
+	c.Header("Cache-Control", "no-store")
+
+	renderer, err := render.New(theme)
+	if err != nil {
+		c.String(http.StatusBadRequest, proto.ErrInvalidArgs)
+		return
+	}
+	entry, code, message := p.getEmbeddable(c, key)
+	if message != "" {
+		c.String(code, message)
+		return
+	}
+	selection, message := parseSnippetSelection(c, entry.Snippets)
+	if message != "" {
+		c.String(http.StatusBadRequest, message)
+		return
+	}
+	recordAudit(ctx, c, log, p.Audit, db.AuditRead, key, "")
+
+	setPasteCacheHeaders(c, entry)
+	if notModified(c) {
+		c.Status(http.StatusNotModified)
+		return
+	}
+
+	css, err := renderer.CSS()
+	if err != nil {
+		log.Errorf("渲染分享内容失败: %+v", err)
+		c.Header("Cache-Control", "no-store")
+		c.String(http.StatusInternalServerError, proto.ErrGetPasteFailed)
+		return
+	}
+	embed := render.Embed{Title: entry.Title, URL: shareURL(c, key), CSS: css}
+	for i, snippet := range entry.Snippets {
+		if selection.Index != 0 && selection.Index != i+1 {
+			continue
+		}
+		rendered, err := renderer.SnippetLines(i+1, snippet.Langtype, snippet.Content, selection.Lines)
+		if err != nil {
+			log.Errorf("渲染分享内容失败: %+v", err)
+			c.Header("Cache-Control", "no-store")
+			c.String(http.StatusInternalServerError, proto.ErrGetPasteFailed)
+			return
+		}
+		embed.Snippets = append(embed.Snippets, rendered)
+	}
+	var buf bytes.Buffer
+	if err := embed.Write(&buf); err != nil {
+		log.Errorf("渲染嵌入页面失败: %+v", err)
+		c.Header("Cache-Control", "no-store")
+		c.String(http.StatusInternalServerError, proto.ErrGetPasteFailed)
+		return
+	}
+
+	config := util.GetConfig().Embed
+	c.Header("Content-Security-Policy", embedContentSecurityPolicy(config))
+	// X-Frame-Options 不支持来源列表，只在仅允许本站嵌入时返回，其他情况以 CSP frame-ancestors 为准
+	if slices.Equal(config.FrameAncestors, []string{"'self'"}) {
+		c.Header("X-Frame-Options", "SAMEORIGIN")
+	}
+	c.Header("X-Content-Type-Options", "nosniff")
+	c.Data(http.StatusOK, "text/html; charset=utf-8", buf.Bytes())
+}
+
+// 返回嵌入分享的脚本，在引用脚本的位置插入 iframe
+func (p *Paste) GetEmbedJS(c *gin.Context) {
+	var (
+		_, log = util.EnsureWithLogger(c)
+		key    = c.Param("key")
+	)
+	c.Set(util.PASTEKEY, key)
+	c.Header("X-Content-Type-Options", "nosniff")
+
+	// 参数在生成脚本时校验，避免嵌入后才在 iframe 中显示错误
+	fail := func(code int, message string) {
+		c.Header("Cache-Control", "no-store")
+		c.Data(code, "application/javascript; charset=utf-8", []byte(fmt.Sprintf("console.error(%q);\n", "paste embed: "+message)))
+	}
+	theme := c.DefaultQuery("theme", render.DefaultTheme)
+	if _, err := render.New(theme); err != nil {
+		fail(http.StatusBadRequest, proto.ErrInvalidArgs)
+		return
+	}
+	entry, code, message := p.getEmbeddable(c, key)
+	if message != "" {
+		fail(code, message)
+		return
+	}
+	if _, message := parseSnippetSelection(c, entry.Snippets); message != "" {
+		fail(http.StatusBadRequest, message)
+		return
+	}
+
+	query := url.Values{}
+	for _, name := range []string{"theme", "snippet", "lines"} {
+		if value := c.Query(name); value != "" {
+			query.Set(name, value)
+		}
+	}
+	src := apiURL(c, fmt.Sprintf("/v1/paste/%s/embed", url.PathEscape(key)))
+	if len(query) > 0 {
+		src += "?" + query.Encode()
+	}
+	title := entry.Title
+	if title == "" {
+		title = "Paste " + key
+	}
+
+	var buf bytes.Buffer
+	if err := embedJSTemplate.Execute(&buf, map[string]string{"URL": src, "Title": title}); err != nil {
+		log.Errorf("生成 embed.js 失败: %+v", err)
+		fail(http.StatusInternalServerError, proto.ErrQueryFailed)
+		return
+	}
+	c.Header("Cache-Control", fmt.Sprintf("public, max-age=%d", previewCacheAge))
+	c.Header("Cross-Origin-Resource-Policy", "cross-origin")
+	c.Data(http.StatusOK, "application/javascript; charset=utf-8", buf.Bytes())
+}
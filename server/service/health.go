@@ -0,0 +1,105 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+
+	"paste.org.cn/paste/server/db"
+	"paste.org.cn/paste/server/proto"
+	"paste.org.cn/paste/server/storage"
+	"paste.org.cn/paste/server/util"
+)
+
+// 健康检查状态
+const (
+	healthOK           = "ok"
+	healthFail         = "fail"
+	healthShuttingDown = "shutting_down"
+)
+
+// 单个依赖检查的超时时间
+const dependencyCheckTimeout = 2 * time.Second
+
+type Health struct{}
+
+// 存活检查，进程能够处理请求即返回成功，不检查依赖
+func (h *Health) Livez(c *gin.Context) {
+	c.JSON(http.StatusOK, proto.HealthResp{
+		Code:   http.StatusOK,
+		Status: healthOK,
+	})
+}
+
+// 就绪检查，检查 MongoDB 和对象存储是否可用，服务关闭过程中返回失败
+func (h *Health) Readyz(c *gin.Context) {
+	ctx, log := util.EnsureWithLogger(c)
+
+	checks := map[string]func(ctx context.Context) error{
+		"mongo": pingMongo,
+	}
+	if storage.StorageConfig.Type == storage.StorageTypeCloud && storage.StorageConfig.OSS != nil {
+		checks["oss"] = storage.StorageConfig.OSS.Ping
+	}
+
+	// 并发检查各依赖，总耗时取决于最慢的依赖
+	var (
+		mu           sync.Mutex
+		wg           sync.WaitGroup
+		dependencies = make(map[string]proto.DependencyStatus, len(checks))
+	)
+	for name, check := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			checkCtx, cancel := context.WithTimeout(ctx, dependencyCheckTimeout)
+			defer cancel()
+
+			start := time.Now()
+			err := check(checkCtx)
+			status := proto.DependencyStatus{
+				Status:    healthOK,
+				LatencyMs: time.Since(start).Milliseconds(),
+			}
+			if err != nil {
+				log.Warnf("就绪检查 %s 失败: %+v", name, err)
+				status.Status = healthFail
+				status.Error = err.Error()
+			}
+
+			mu.Lock()
+			dependencies[name] = status
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	resp := proto.HealthResp{
+		Code:         http.StatusOK,
+		Status:       healthOK,
+		Dependencies: dependencies,
+	}
+	for _, status := range dependencies {
+		if status.Status != healthOK {
+			resp.Code, resp.Status = http.StatusServiceUnavailable, healthFail
+		}
+	}
+	if !util.IsReady() {
+		resp.Code, resp.Status = http.StatusServiceUnavailable, healthShuttingDown
+	}
+	c.JSON(resp.Code, resp)
+}
+
+// pingMongo 检查 MongoDB 主节点是否可用
+func pingMongo(ctx context.Context) error {
+	client := db.GetMongoClient()
+	if client == nil {
+		return errors.New("mongo client is not initialized")
+	}
+	return client.Ping(ctx, readpref.Primary())
+}
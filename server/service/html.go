@@ -0,0 +1,109 @@
+package service
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/gin-gonic/gin"
+
+	"paste.org.cn/paste/server/db"
+	"paste.org.cn/paste/server/proto"
+	"paste.org.cn/paste/server/render"
+	"paste.org.cn/paste/server/util"
+)
+
+// 渲染后的页面只需要内联样式和图片，不允许执行脚本
+const htmlContentSecurityPolicy = "default-src 'none'; style-src 'unsafe-inline'; img-src 'self' https: data:; form-action 'self'"
+
+// 以 HTML 页面返回分享内容，供不能执行 JavaScript 的客户端使用
+func (p *Paste) GetPasteHTML(c *gin.Context) {
+	var (
+		_, log        = util.EnsureWithLogger(c)
+		key, password = c.Param("key"), c.Query("password")
+		theme         = c.DefaultQuery("theme", render.DefaultTheme)
+	)
+	c.Set(util.PASTEKEY, key)
+	c.Header("Cache-Control", "no-store")
+
+	// 先校验主题，避免一次性分享因为参数错误被销毁
+	renderer, err := render.New(theme)
+	if err != nil {
+		c.String(http.StatusBadRequest, proto.ErrInvalidArgs)
+		return
+	}
+
+	entry, ok := p.getPasteText(c, key, password)
+	if !ok {
+		return
+	}
+
+	setPasteCacheHeaders(c, entry)
+	if !entry.Once && notModified(c) {
+		c.Status(http.StatusNotModified)
+		return
+	}
+
+	page, err := newHTMLPage(renderer, entry, theme, password)
+	if err != nil {
+		log.Errorf("渲染分享内容失败: %+v", err)
+		c.Header("Cache-Control", "no-store")
+		c.String(http.StatusInternalServerError, proto.ErrGetPasteFailed)
+		return
+	}
+	var buf bytes.Buffer
+	if err := page.Write(&buf); err != nil {
+		log.Errorf("渲染分享页面失败: %+v", err)
+		c.Header("Cache-Control", "no-store")
+		c.String(http.StatusInternalServerError, proto.ErrGetPasteFailed)
+		return
+	}
+
+	c.Header("Content-Security-Policy", htmlContentSecurityPolicy)
+	c.Header("X-Content-Type-Options", "nosniff")
+	c.Data(http.StatusOK, "text/html; charset=utf-8", buf.Bytes())
+}
+
+// newHTMLPage 渲染分享的所有片段，一次性分享已被销毁，不列出附件下载链接
+func newHTMLPage(renderer *render.Renderer, entry db.PasteEntry, theme, password string) (render.Page, error) {
+	css, err := renderer.CSS()
+	if err != nil {
+		return render.Page{}, err
+	}
+	page := render.Page{
+		Title:       entry.Title,
+		Description: entry.Description,
+		Theme:       theme,
+		Themes:      render.Themes(),
+		Password:    password,
+		CSS:         css,
+	}
+	for i, snippet := range entry.Snippets {
+		rendered, err := renderer.Snippet(i+1, snippet.Langtype, snippet.Content)
+		if err != nil {
+			return render.Page{}, fmt.Errorf("snippet %d: %w", i+1, err)
+		}
+		page.Snippets = append(page.Snippets, rendered)
+	}
+
+	if entry.Once {
+		return page, nil
+	}
+	query := ""
+	if password != "" {
+		query = "?" + url.Values{"password": {password}}.Encode()
+	}
+	for i, attachment := range entry.Attachments {
+		name := attachment.Name
+		if name == "" {
+			name = attachment.Filename
+		}
+		page.Attachments = append(page.Attachments, render.Link{
+			Name: name,
+			URL:  fmt.Sprintf("/v1/paste/%s/attachments/%d%s", url.PathEscape(entry.Key), i, query),
+			Size: attachment.Size,
+		})
+	}
+	return page, nil
+}
@@ -0,0 +1,83 @@
+package service
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"paste.org.cn/paste/server/db"
+	"paste.org.cn/paste/server/storage"
+)
+
+const (
+	// 普通分享的最长缓存时间，分享内容不可修改，但管理员仍可能删除
+	pasteMaxAge = time.Hour
+	// 包含云存储图片时的最长缓存时间，签名URL缓存到有效期结束前 1 分钟，缓存的响应不能比签名URL活得更久
+	signedURLMaxAge = time.Minute
+)
+
+// setPasteCacheHeaders 根据分享的属性设置 ETag、Last-Modified 和 Cache-Control 响应头
+// 一次性分享读取后即销毁，不允许任何缓存；有密码的分享只允许在浏览器内重新验证，不允许存储
+func setPasteCacheHeaders(c *gin.Context, entry db.PasteEntry) {
+	if entry.Once {
+		c.Header("Cache-Control", "no-store")
+		return
+	}
+
+	hash := entry.ContentHash
+	if hash == "" {
+		hash = entry.Hash()
+	}
+	// 响应中的签名URL每次可能不同，使用弱 ETag
+	c.Header("ETag", fmt.Sprintf(`W/"%s"`, hash))
+	c.Header("Last-Modified", entry.CreatedAt.UTC().Format(http.TimeFormat))
+
+	if entry.Password != "" {
+		c.Header("Cache-Control", "private, no-store")
+		return
+	}
+
+	maxAge := pasteMaxAge
+	if len(entry.Attachments) > 0 && entry.Attachments[0].StorageType == storage.StorageTypeCloud {
+		maxAge = signedURLMaxAge
+	}
+	if !entry.ExpireAt.IsZero() {
+		maxAge = min(maxAge, time.Until(entry.ExpireAt))
+	}
+	c.Header("Cache-Control", fmt.Sprintf("public, max-age=%d", max(int(maxAge.Seconds()), 0)))
+}
+
+// notModified 判断条件请求是否可以返回 304，需要在 setPasteCacheHeaders 之后调用
+// 同时携带 If-None-Match 和 If-Modified-Since 时，只使用 If-None-Match
+func notModified(c *gin.Context) bool {
+	etag := c.Writer.Header().Get("ETag")
+	if etag == "" {
+		return false
+	}
+
+	if inm := c.GetHeader("If-None-Match"); inm != "" {
+		if strings.TrimSpace(inm) == "*" {
+			return true
+		}
+		// 弱比较：忽略 W/ 前缀
+		for _, candidate := range strings.Split(inm, ",") {
+			if strings.TrimPrefix(strings.TrimSpace(candidate), "W/") == strings.TrimPrefix(etag, "W/") {
+				return true
+			}
+		}
+		return false
+	}
+
+	if ims := c.GetHeader("If-Modified-Since"); ims != "" {
+		since, err := http.ParseTime(ims)
+		if err != nil {
+			return false
+		}
+		lastModified, err := http.ParseTime(c.Writer.Header().Get("Last-Modified"))
+		return err == nil && !lastModified.After(since)
+	}
+	return false
+}
@@ -0,0 +1,62 @@
+package service
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+
+	"paste.org.cn/paste/server/lang"
+	"paste.org.cn/paste/server/proto"
+)
+
+// normalizeLangtypes 将片段的 langtype 转换为规范的 ID，例如 golang、Go 转换为 go，返回不支持的 langtype
+func normalizeLangtypes(snippets []proto.Snippet) (string, bool) {
+	for i := range snippets {
+		id, ok := lang.Normalize(snippets[i].Langtype)
+		if !ok {
+			return snippets[i].Langtype, false
+		}
+		snippets[i].Langtype = id
+	}
+	return "", true
+}
+
+// detectLangtypes 为没有指定语言的片段自动识别语言，用户明确选择的语言保持不变
+func detectLangtypes(log *logrus.Entry, snippets []proto.Snippet) {
+	for i := range snippets {
+		snippet := &snippets[i]
+		// 识别结果只能由服务端写入
+		snippet.Detected, snippet.Confidence = false, 0
+		if !lang.IsUndetermined(snippet.Langtype) {
+			continue
+		}
+		langtype, confidence := lang.Detect(snippet.Content)
+		if langtype == "" {
+			continue
+		}
+		log.Debugf("片段 %d 识别为 %s，置信度 %.2f", i+1, langtype, confidence)
+		snippet.Langtype, snippet.Detected, snippet.Confidence = langtype, true, confidence
+	}
+}
+
+// 获取支持的语言类型
+func (p *Paste) GetLanguages(c *gin.Context) {
+	languages := lang.Languages()
+	resp := proto.LanguagesResp{
+		Code:      http.StatusOK,
+		Languages: make([]proto.Language, 0, len(languages)),
+	}
+	for _, language := range languages {
+		resp.Languages = append(resp.Languages, proto.Language{
+			ID:         language.ID,
+			Name:       language.Name,
+			Aliases:    language.Aliases,
+			Extensions: language.Extensions,
+			MIMEType:   language.MIMEType,
+		})
+	}
+	// 列表随版本发布变化，允许客户端缓存
+	c.Header("Cache-Control", "public, max-age=3600")
+	c.JSON(http.StatusOK, resp)
+}
@@ -0,0 +1,135 @@
+package service
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"paste.org.cn/paste/server/proto"
+	"paste.org.cn/paste/server/render"
+)
+
+// snippetSelection 表示请求中通过 snippet 和 lines 参数选择的内容
+type snippetSelection struct {
+	Index int              // 选择的片段序号，从 1 开始，0 表示全部片段
+	Lines render.LineRange // 选择的行范围，零值表示全部行
+}
+
+// parseSnippetSelection 解析 snippet 和 lines 参数并按片段内容校验，只指定 lines 时选择第一个片段，
+// 失败时返回错误信息
+func parseSnippetSelection(c *gin.Context, snippets []proto.Snippet) (snippetSelection, string) {
+	selection, message := parseSnippetQuery(c)
+	if message != "" {
+		return selection, message
+	}
+	return selection, selection.validate(snippets)
+}
+
+// parseSnippetQuery 只校验 snippet 和 lines 参数的格式，不需要读取分享内容
+func parseSnippetQuery(c *gin.Context) (snippetSelection, string) {
+	var selection snippetSelection
+	snippet, lines := c.Query("snippet"), c.Query("lines")
+	if snippet == "" && lines == "" {
+		return selection, ""
+	}
+
+	selection.Index = 1
+	if snippet != "" {
+		index, err := strconv.Atoi(snippet)
+		if err != nil || index < 1 {
+			return selection, proto.ErrInvalidSnippet
+		}
+		selection.Index = index
+	}
+	if lines != "" {
+		r, ok := parseLineRange(lines)
+		if !ok {
+			return selection, proto.ErrInvalidLines
+		}
+		selection.Lines = r
+	}
+	return selection, ""
+}
+
+// validate 检查选择的片段和行范围是否在分享内容之内，失败时返回错误信息
+func (s snippetSelection) validate(snippets []proto.Snippet) string {
+	if s.Index == 0 {
+		return ""
+	}
+	if s.Index > len(snippets) {
+		return proto.ErrInvalidSnippet
+	}
+	if s.Lines.End > countLines(snippets[s.Index-1].Content) {
+		return proto.ErrInvalidLines
+	}
+	return ""
+}
+
+// apply 返回选择的片段，指定行范围时片段内容只包含这些行，同时返回行号信息
+func (s snippetSelection) apply(snippets []proto.Snippet) ([]proto.Snippet, *proto.LineSelection) {
+	if s.Index == 0 {
+		return snippets, nil
+	}
+	snippet := snippets[s.Index-1]
+	total := countLines(snippet.Content)
+	selection := &proto.LineSelection{Snippet: s.Index, StartLine: min(1, total), EndLine: total, TotalLines: total}
+	if !s.Lines.IsZero() {
+		snippet.Content = sliceLines(snippet.Content, s.Lines)
+		selection.StartLine, selection.EndLine = s.Lines.Start, s.Lines.End
+	}
+	return []proto.Snippet{snippet}, selection
+}
+
+// parseLineRange 解析 10-20 或 10 形式的行范围，行号从 1 开始
+func parseLineRange(s string) (render.LineRange, bool) {
+	first, last, found := strings.Cut(s, "-")
+	start, err := strconv.Atoi(first)
+	if err != nil {
+		return render.LineRange{}, false
+	}
+	end := start
+	if found {
+		if end, err = strconv.Atoi(last); err != nil {
+			return render.LineRange{}, false
+		}
+	}
+	if start < 1 || end < start {
+		return render.LineRange{}, false
+	}
+	return render.LineRange{Start: start, End: end}, true
+}
+
+// sliceLines 返回 content 中第 r.Start 到 r.End 行的原始内容，保留行尾的换行
+func sliceLines(content string, r render.LineRange) string {
+	begin, line := 0, 1
+	for line < r.Start {
+		i := strings.IndexByte(content[begin:], '\n')
+		if i < 0 {
+			return ""
+		}
+		begin += i + 1
+		line++
+	}
+	end := begin
+	for ; line <= r.End; line++ {
+		i := strings.IndexByte(content[end:], '\n')
+		if i < 0 {
+			return content[begin:]
+		}
+		end += i + 1
+	}
+	return content[begin:end]
+}
+
+// countLines 返回内容的行数，末尾的换行不计为新的一行
+func countLines(content string) int {
+	if content == "" {
+		return 0
+	}
+	n := strings.Count(content, "\n")
+	if !strings.HasSuffix(content, "\n") {
+		n++
+	}
+	return n
+}
@@ -0,0 +1,131 @@
+package service
+
+import (
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"paste.org.cn/paste/server/proto"
+	"paste.org.cn/paste/server/render"
+)
+
+func TestParseLineRange(t *testing.T) {
+	tests := []struct {
+		s    string
+		want render.LineRange
+		ok   bool
+	}{
+		{"10", render.LineRange{Start: 10, End: 10}, true},
+		{"10-20", render.LineRange{Start: 10, End: 20}, true},
+		{"1-1", render.LineRange{Start: 1, End: 1}, true},
+		{"0", render.LineRange{}, false},
+		{"20-10", render.LineRange{}, false},
+		{"-5", render.LineRange{}, false},
+		{"5-", render.LineRange{}, false},
+		{"1-2-3", render.LineRange{}, false},
+		{"a-b", render.LineRange{}, false},
+		{"", render.LineRange{}, false},
+	}
+	for _, tt := range tests {
+		got, ok := parseLineRange(tt.s)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("parseLineRange(%q) = %+v, %v, want %+v, %v", tt.s, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestCountLines(t *testing.T) {
+	tests := []struct {
+		content string
+		want    int
+	}{
+		{"", 0},
+		{"a", 1},
+		{"a\n", 1},
+		{"a\nb", 2},
+		{"a\nb\n", 2},
+		{"\n", 1},
+		{"\n\n", 2},
+		{"a\r\nb\r\n", 2},
+	}
+	for _, tt := range tests {
+		if got := countLines(tt.content); got != tt.want {
+			t.Errorf("countLines(%q) = %d, want %d", tt.content, got, tt.want)
+		}
+	}
+}
+
+func TestSliceLines(t *testing.T) {
+	content := "one\ntwo\nthree\nfour"
+	tests := []struct {
+		start, end int
+		want       string
+	}{
+		{1, 1, "one\n"},
+		{2, 3, "two\nthree\n"},
+		{3, 4, "three\nfour"},
+		{4, 4, "four"},
+		{1, 4, content},
+		{2, 10, "two\nthree\nfour"},
+		{5, 5, ""},
+	}
+	for _, tt := range tests {
+		if got := sliceLines(content, render.LineRange{Start: tt.start, End: tt.end}); got != tt.want {
+			t.Errorf("sliceLines(%d-%d) = %q, want %q", tt.start, tt.end, got, tt.want)
+		}
+	}
+}
+
+func TestSnippetSelection(t *testing.T) {
+	snippets := []proto.Snippet{
+		{Langtype: "go", Content: "package main\n\nfunc main() {}\n"},
+		{Langtype: "python", Content: "a = 1\nb = 2"},
+	}
+	tests := []struct {
+		name      string
+		query     string
+		message   string
+		contents  []string
+		selection *proto.LineSelection
+	}{
+		{"全部片段", "", "", []string{snippets[0].Content, snippets[1].Content}, nil},
+		{"只指定 lines 时选择第一个片段", "lines=2-3", "", []string{"\nfunc main() {}\n"},
+			&proto.LineSelection{Snippet: 1, StartLine: 2, EndLine: 3, TotalLines: 3}},
+		{"整个片段", "snippet=2", "", []string{snippets[1].Content},
+			&proto.LineSelection{Snippet: 2, StartLine: 1, EndLine: 2, TotalLines: 2}},
+		{"片段和单行", "snippet=2&lines=2", "", []string{"b = 2"},
+			&proto.LineSelection{Snippet: 2, StartLine: 2, EndLine: 2, TotalLines: 2}},
+		{"片段不存在", "snippet=3", proto.ErrInvalidSnippet, nil, nil},
+		{"片段序号无效", "snippet=0", proto.ErrInvalidSnippet, nil, nil},
+		{"片段序号不是数字", "snippet=a", proto.ErrInvalidSnippet, nil, nil},
+		{"行号超出片段", "snippet=2&lines=2-3", proto.ErrInvalidLines, nil, nil},
+		{"行范围格式错误", "lines=3-1", proto.ErrInvalidLines, nil, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest("GET", "/v1/paste/abcd?"+tt.query, nil)
+			selection, message := parseSnippetSelection(c, snippets)
+			if message != tt.message {
+				t.Fatalf("parseSnippetSelection() message = %q, want %q", message, tt.message)
+			}
+			if message != "" {
+				return
+			}
+
+			got, lines := selection.apply(snippets)
+			var contents []string
+			for _, snippet := range got {
+				contents = append(contents, snippet.Content)
+			}
+			if !reflect.DeepEqual(contents, tt.contents) {
+				t.Errorf("apply() contents = %q, want %q", contents, tt.contents)
+			}
+			if !reflect.DeepEqual(lines, tt.selection) {
+				t.Errorf("apply() selection = %+v, want %+v", lines, tt.selection)
+			}
+		})
+	}
+}
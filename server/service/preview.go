@@ -0,0 +1,225 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"paste.org.cn/paste/server/db"
+	"paste.org.cn/paste/server/proto"
+	"paste.org.cn/paste/server/render"
+	"paste.org.cn/paste/server/storage"
+	"paste.org.cn/paste/server/util"
+)
+
+// 链接预览中显示的服务名称
+const previewSiteName = "Paste"
+
+// 链接预览的内容长度，聊天工具通常只显示开头几行
+const (
+	previewDescriptionLength = 200 // og:description 的最大字符数
+	previewExcerptLines      = 10  // 页面中显示的片段行数
+	previewExcerptLength     = 500 // 页面中显示的片段最大字符数
+)
+
+// 预览不包含一次性分享和有密码分享的内容，可以短时间缓存
+const previewCacheAge = 300
+
+// 返回带 Open Graph 标签的预览页面，供聊天工具展开链接，不会销毁一次性分享
+func (p *Paste) GetPastePreview(c *gin.Context) {
+	var (
+		ctx, log = util.EnsureWithLogger(c)
+		key      = c.Param("key")
+	)
+	c.Set(util.PASTEKEY, key)
+
+	entry, err := p.Paste.Preview(ctx, key)
+	if err != nil {
+		c.Header("Cache-Control", "no-store")
+		switch {
+		case errors.Is(err, mongo.ErrNoDocuments):
+			c.String(http.StatusNotFound, proto.ErrNotFound)
+		case err.Error() == proto.ErrContentExpired:
+			c.String(http.StatusGone, proto.ErrContentExpired)
+		default:
+			log.Errorf("获取预览内容失败: %+v", err)
+			c.String(http.StatusInternalServerError, proto.ErrQueryFailed)
+		}
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := newPreview(ctx, c, log, key, entry).Write(&buf); err != nil {
+		log.Errorf("渲染预览页面失败: %+v", err)
+		c.Header("Cache-Control", "no-store")
+		c.String(http.StatusInternalServerError, proto.ErrQueryFailed)
+		return
+	}
+
+	c.Header("Cache-Control", fmt.Sprintf("public, max-age=%d", previewCacheAge))
+	c.Header("Content-Security-Policy", htmlContentSecurityPolicy)
+	c.Header("X-Content-Type-Options", "nosniff")
+	c.Header("X-Robots-Tag", "noindex")
+	c.Data(http.StatusOK, "text/html; charset=utf-8", buf.Bytes())
+}
+
+// 按 oEmbed 协议返回分享链接的预览信息
+func (p *Paste) GetOEmbed(c *gin.Context) {
+	ctx, log := util.EnsureWithLogger(c)
+	if format := c.DefaultQuery("format", "json"); format != "json" {
+		c.JSON(http.StatusNotImplemented, proto.OEmbedResp{
+			Code:    http.StatusNotImplemented,
+			Message: proto.ErrInvalidArgs,
+		})
+		return
+	}
+	key, ok := keyFromURL(c.Query("url"))
+	if !ok {
+		c.JSON(http.StatusNotFound, proto.OEmbedResp{
+			Code:    http.StatusNotFound,
+			Message: proto.ErrNotFound,
+		})
+		return
+	}
+	c.Set(util.PASTEKEY, key)
+
+	entry, err := p.Paste.Preview(ctx, key)
+	if err != nil {
+		switch {
+		case errors.Is(err, mongo.ErrNoDocuments), err.Error() == proto.ErrContentExpired:
+			c.JSON(http.StatusNotFound, proto.OEmbedResp{
+				Code:    http.StatusNotFound,
+				Message: proto.ErrNotFound,
+			})
+		default:
+			log.Errorf("获取预览内容失败: %+v", err)
+			c.JSON(http.StatusInternalServerError, proto.OEmbedResp{
+				Code:    http.StatusInternalServerError,
+				Message: proto.ErrQueryFailed,
+			})
+		}
+		return
+	}
+
+	preview := newPreview(ctx, c, log, key, entry)
+	resp := proto.OEmbedResp{
+		Code:         http.StatusOK,
+		Version:      "1.0",
+		Type:         "link",
+		Title:        preview.Title,
+		ProviderName: previewSiteName,
+		ProviderURL:  publicBaseURL(c) + "/",
+		CacheAge:     previewCacheAge,
+	}
+	// 图片超过客户端要求的尺寸时不返回缩略图
+	maxWidth, _ := strconv.Atoi(c.Query("maxwidth"))
+	maxHeight, _ := strconv.Atoi(c.Query("maxheight"))
+	if preview.Image != "" && (maxWidth <= 0 || preview.ImageWidth <= maxWidth) && (maxHeight <= 0 || preview.ImageHeight <= maxHeight) {
+		resp.ThumbnailURL, resp.ThumbnailWidth, resp.ThumbnailHeight = preview.Image, preview.ImageWidth, preview.ImageHeight
+	}
+	c.Header("Cache-Control", fmt.Sprintf("public, max-age=%d", previewCacheAge))
+	c.JSON(http.StatusOK, resp)
+}
+
+// keyFromURL 从分享链接 /<key> 或接口地址 /[api/]v1/paste/<key>[/...] 中取出 key
+func keyFromURL(raw string) (string, bool) {
+	u, err := url.Parse(raw)
+	if err != nil || u.Path == "" {
+		return "", false
+	}
+	segments := strings.Split(strings.Trim(u.Path, "/"), "/")
+	key := segments[len(segments)-1]
+	if i := slices.Index(segments, "paste"); i >= 0 && i+1 < len(segments) {
+		key = segments[i+1]
+	}
+	return key, util.IsValidKey(key)
+}
+
+// newPreview 生成链接预览，一次性分享和有密码的分享只显示提示，不包含标题、片段和图片
+func newPreview(ctx context.Context, c *gin.Context, log *logrus.Entry, key string, entry db.PasteEntry) render.Preview {
+	preview := render.Preview{
+		SiteName:  previewSiteName,
+		URL:       shareURL(c, key),
+		OEmbedURL: apiURL(c, "/v1/oembed") + "?" + url.Values{"url": {shareURL(c, key)}, "format": {"json"}}.Encode(),
+	}
+	switch {
+	case entry.Once:
+		preview.Title = "阅后即焚的分享"
+		preview.Description = "打开后内容即被销毁，预览中不显示内容"
+		return preview
+	case entry.Password != "":
+		preview.Title = "受密码保护的分享"
+		preview.Description = "需要输入密码才能查看内容"
+		return preview
+	}
+
+	preview.Title = entry.Title
+	if preview.Title == "" {
+		preview.Title = "分享 " + key
+	}
+	if len(entry.Snippets) > 0 {
+		preview.Excerpt = firstLines(entry.Snippets[0].Content, previewExcerptLines, previewExcerptLength)
+	}
+	preview.Description = entry.Description
+	if preview.Description == "" {
+		preview.Description = preview.Excerpt
+	}
+	preview.Description = truncateRunes(preview.Description, previewDescriptionLength)
+	preview.Image, preview.ImageWidth, preview.ImageHeight = previewImage(ctx, c, log, key, entry)
+	return preview
+}
+
+// previewImage 返回第一张图片的绝对地址和尺寸，云存储优先使用缩略图的签名URL，base64 存储使用下载附件接口
+func previewImage(ctx context.Context, c *gin.Context, log *logrus.Entry, key string, entry db.PasteEntry) (string, int, int) {
+	for i, attachment := range entry.Attachments {
+		if !attachment.IsImage() {
+			continue
+		}
+		if attachment.StorageType != storage.StorageTypeCloud {
+			return apiURL(c, fmt.Sprintf("/v1/paste/%s/attachments/%d", url.PathEscape(key), i)), attachment.Width, attachment.Height
+		}
+
+		objectKey, width, height := attachment.ObjectKey, attachment.Width, attachment.Height
+		if thumbnail := attachment.Thumbnail; thumbnail != nil && thumbnail.ObjectKey != "" {
+			objectKey, width, height = thumbnail.ObjectKey, thumbnail.Width, thumbnail.Height
+		}
+		if objectKey == "" || storage.StorageConfig.OSS == nil {
+			return "", 0, 0
+		}
+		signedURL, err := storage.StorageConfig.OSS.GetSignedURL(ctx, objectKey)
+		if err != nil {
+			log.Warnf("为 objectKey '%s' 生成签名URL失败: %+v", objectKey, err)
+			return "", 0, 0
+		}
+		return signedURL, width, height
+	}
+	return "", 0, 0
+}
+
+// firstLines 返回 content 的前 n 行，总长度不超过 maxLength 个字符
+func firstLines(content string, n, maxLength int) string {
+	lines := strings.SplitN(strings.ReplaceAll(content, "\r\n", "\n"), "\n", n+1)
+	if len(lines) > n {
+		lines = lines[:n]
+	}
+	return truncateRunes(strings.TrimRight(strings.Join(lines, "\n"), "\n"), maxLength)
+}
+
+// truncateRunes 将 s 截断为不超过 n 个字符，截断时以省略号结尾
+func truncateRunes(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n-1]) + "…"
+}
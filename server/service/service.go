@@ -2,21 +2,64 @@ package service
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"net/url"
+	"strconv"
 	"time"
 	"unicode/utf8"
 
 	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/mongo"
 
 	"paste.org.cn/paste/server/db"
+	"paste.org.cn/paste/server/middleware"
 	"paste.org.cn/paste/server/proto"
 	"paste.org.cn/paste/server/storage"
 	"paste.org.cn/paste/server/util"
 )
 
+// 举报原因的最大长度（字符）
+const reportReasonMaxLength = 500
+
 type Paste struct {
 	db.Paste
+	Audit   db.Audit  // 分享生命周期审计事件
+	Uploads db.Upload // 可续传上传
+}
+
+// uploadErrorMessage 返回附件保存失败时告知客户端的错误信息
+func uploadErrorMessage(err error) string {
+	switch msg := err.Error(); msg {
+	case proto.ErrInvalidFileType, // 告知客户端允许的图片格式
+		proto.ErrAttachmentType, // 告知客户端允许的附件类型
+		proto.ErrUploadNotFound: // 告知客户端需要重新上传
+		return msg
+	}
+	return proto.ErrUploadFailed
+}
+
+// checkCustomKey 校验自定义 key，只有认证用户可以使用，返回失败时的状态码和错误信息
+func checkCustomKey(c *gin.Context, key string) (int, string) {
+	if _, ok := middleware.CurrentUser(c); !ok {
+		return http.StatusUnauthorized, proto.ErrUnauthorized
+	}
+	if !util.IsValidKey(key) {
+		return http.StatusBadRequest, proto.ErrInvalidKey
+	}
+	if util.IsReservedKey(key) {
+		return http.StatusBadRequest, proto.ErrKeyReserved
+	}
+	return http.StatusOK, ""
+}
+
+// setErrorResponse 返回保存分享失败时的状态码和错误信息
+func setErrorResponse(err error) (int, string) {
+	if err.Error() == proto.ErrKeyExists {
+		return http.StatusConflict, proto.ErrKeyExists
+	}
+	return http.StatusBadRequest, proto.ErrPasteFailed
 }
 
 // 创建分享内容
@@ -24,9 +67,27 @@ func (p *Paste) PostPaste(c *gin.Context) {
 	var (
 		ctx, log = util.EnsureWithLogger(c)
 		req      proto.PostPasteReq
+		key      string
 		err      error
 	)
 
+	// 按顺序读取请求体并保存图片和附件，普通字段写回请求后照常绑定
+	req.Attachments, err = storage.UploadAttachments(c, log, p.Paste.Blobs())
+	if err != nil {
+		log.Errorf("获取附件失败: %+v", err)
+		c.JSON(http.StatusBadRequest, proto.PostPasteResp{
+			Code:    http.StatusBadRequest,
+			Message: uploadErrorMessage(err),
+		})
+		return
+	}
+	// 后续校验或保存失败时释放已保存附件的引用
+	defer func() {
+		if key == "" {
+			storage.ReleaseAttachments(ctx, p.Paste.Blobs(), req.Attachments)
+		}
+	}()
+
 	// 使用 ShouldBind 绑定基本字段
 	if err = c.ShouldBind(&req); err != nil {
 		log.Errorf("绑定请求数据失败: %+v", err)
@@ -48,7 +109,21 @@ func (p *Paste) PostPaste(c *gin.Context) {
 		return
 	}
 
-	if len(req.Snippets) == 0 && len(req.Images) == 0 {
+	// 引用已完成的可续传上传
+	if len(req.Uploads) > 0 {
+		attachments, err := storage.ClaimUploads(c, log, p.Uploads, p.Paste.Blobs(), req.Uploads, req.Attachments)
+		if err != nil {
+			log.Errorf("引用可续传上传失败: %+v", err)
+			c.JSON(http.StatusBadRequest, proto.PostPasteResp{
+				Code:    http.StatusBadRequest,
+				Message: uploadErrorMessage(err),
+			})
+			return
+		}
+		req.Attachments = append(req.Attachments, attachments...)
+	}
+
+	if len(req.Snippets) == 0 && len(req.Attachments) == 0 {
 		log.Errorf("内容为空")
 		c.JSON(http.StatusBadRequest, proto.PostPasteResp{
 			Code:    http.StatusBadRequest,
@@ -80,28 +155,42 @@ func (p *Paste) PostPaste(c *gin.Context) {
 		}
 	}
 
-	req.Images, err = storage.UploadImages(c, log)
-	if err != nil {
-		log.Errorf("获取图片失败: %+v", err)
+	// 规范化语言类型，没有指定语言的片段自动识别语言
+	if langtype, ok := normalizeLangtypes(req.Snippets); !ok {
+		log.Errorf("不支持的语言类型: %s", langtype)
 		c.JSON(http.StatusBadRequest, proto.PostPasteResp{
 			Code:    http.StatusBadRequest,
-			Message: proto.ErrUploadFailed,
+			Message: proto.ErrInvalidLangtype,
 		})
 		return
 	}
+	detectLangtypes(log, req.Snippets)
+
+	// 自定义 key
+	if req.Key != "" {
+		if code, message := checkCustomKey(c, req.Key); message != "" {
+			log.Errorf("自定义 key '%s' 不可用: %s", req.Key, message)
+			c.JSON(code, proto.PostPasteResp{
+				Code:    code,
+				Message: message,
+			})
+			return
+		}
+	}
 
 	entry := db.PasteEntry{
+		Key:         req.Key,
 		Title:       req.Title,
 		Description: req.Description,
 		Snippets:    req.Snippets,
-		Images:      req.Images,
+		Attachments: req.Attachments,
 		ClientIP:    c.ClientIP(),
 		CreatedAt:   time.Now(),
 	}
 
 	// 设置密码（如果有）
 	if req.Password != "" {
-		entry.Password = util.String2bcrypt(req.Password)
+		entry.Password = util.String2bcrypt(ctx, req.Password)
 	}
 
 	// 设置过期时间（如果有）
@@ -110,20 +199,25 @@ func (p *Paste) PostPaste(c *gin.Context) {
 	}
 
 	// 保存到数据库
-	key, err := p.Paste.Set(ctx, entry)
+	key, err = p.Paste.Set(ctx, entry)
 	if err != nil {
 		log.Errorf("插入数据库失败: %+v", err)
-		c.JSON(http.StatusBadRequest, proto.PostPasteResp{
-			Code:    http.StatusBadRequest,
-			Message: proto.ErrPasteFailed,
+		code, message := setErrorResponse(err)
+		c.JSON(code, proto.PostPasteResp{
+			Code:    code,
+			Message: message,
 		})
 		return
 	}
 
+	c.Set(util.PASTEKEY, key)
+	recordAudit(ctx, c, log, p.Audit, db.AuditCreated, key, "")
+
 	// 返回成功响应
 	c.JSON(http.StatusCreated, proto.PostPasteResp{
 		Code: http.StatusCreated,
 		Key:  key,
+		URL:  shareURL(c, key),
 	})
 }
 
@@ -133,9 +227,27 @@ func (p *Paste) PostPasteOnce(c *gin.Context) {
 	var (
 		ctx, log = util.EnsureWithLogger(c)
 		req      proto.PostPasteReq
+		key      string
 		err      error
 	)
 
+	// 按顺序读取请求体并保存图片和附件，普通字段写回请求后照常绑定
+	req.Attachments, err = storage.UploadAttachments(c, log, p.Paste.Blobs())
+	if err != nil {
+		log.Errorf("获取附件失败: %+v", err)
+		c.JSON(http.StatusBadRequest, proto.PostPasteResp{
+			Code:    http.StatusBadRequest,
+			Message: uploadErrorMessage(err),
+		})
+		return
+	}
+	// 后续校验或保存失败时释放已保存附件的引用
+	defer func() {
+		if key == "" {
+			storage.ReleaseAttachments(ctx, p.Paste.Blobs(), req.Attachments)
+		}
+	}()
+
 	// 使用 ShouldBind 绑定基本字段
 	if err = c.ShouldBind(&req); err != nil {
 		log.Errorf("绑定请求数据失败: %+v", err)
@@ -157,6 +269,20 @@ func (p *Paste) PostPasteOnce(c *gin.Context) {
 		return
 	}
 
+	// 引用已完成的可续传上传
+	if len(req.Uploads) > 0 {
+		attachments, err := storage.ClaimUploads(c, log, p.Uploads, p.Paste.Blobs(), req.Uploads, req.Attachments)
+		if err != nil {
+			log.Errorf("引用可续传上传失败: %+v", err)
+			c.JSON(http.StatusBadRequest, proto.PostPasteResp{
+				Code:    http.StatusBadRequest,
+				Message: uploadErrorMessage(err),
+			})
+			return
+		}
+		req.Attachments = append(req.Attachments, attachments...)
+	}
+
 	// 验证代码片段内容
 	for _, snippet := range req.Snippets {
 		length := utf8.RuneCountInString(snippet.Content)
@@ -170,23 +296,36 @@ func (p *Paste) PostPasteOnce(c *gin.Context) {
 		}
 	}
 
-	// 获取图片
-	req.Images, err = storage.UploadImages(c, log)
-	if err != nil {
-		log.Errorf("获取图片失败: %+v", err)
+	// 规范化语言类型，没有指定语言的片段自动识别语言
+	if langtype, ok := normalizeLangtypes(req.Snippets); !ok {
+		log.Errorf("不支持的语言类型: %s", langtype)
 		c.JSON(http.StatusBadRequest, proto.PostPasteResp{
 			Code:    http.StatusBadRequest,
-			Message: proto.ErrUploadFailed,
+			Message: proto.ErrInvalidLangtype,
 		})
 		return
 	}
+	detectLangtypes(log, req.Snippets)
+
+	// 自定义 key
+	if req.Key != "" {
+		if code, message := checkCustomKey(c, req.Key); message != "" {
+			log.Errorf("自定义 key '%s' 不可用: %s", req.Key, message)
+			c.JSON(code, proto.PostPasteResp{
+				Code:    code,
+				Message: message,
+			})
+			return
+		}
+	}
 
 	// 创建数据库记录
 	entry := db.PasteEntry{
+		Key:         req.Key,
 		Title:       req.Title,
 		Description: req.Description,
 		Snippets:    req.Snippets,
-		Images:      req.Images,
+		Attachments: req.Attachments,
 		ClientIP:    c.ClientIP(),
 		Once:        true, // 标记为一次性
 		CreatedAt:   time.Now(),
@@ -194,24 +333,29 @@ func (p *Paste) PostPasteOnce(c *gin.Context) {
 
 	// 设置密码（如果有）
 	if req.Password != "" {
-		entry.Password = util.String2bcrypt(req.Password)
+		entry.Password = util.String2bcrypt(ctx, req.Password)
 	}
 
 	// 保存到数据库
-	key, err := p.Paste.Set(ctx, entry)
+	key, err = p.Paste.Set(ctx, entry)
 	if err != nil {
 		log.Errorf("插入数据库失败: %+v", err)
-		c.JSON(http.StatusBadRequest, proto.PostPasteResp{
-			Code:    http.StatusBadRequest,
-			Message: proto.ErrPasteFailed,
+		code, message := setErrorResponse(err)
+		c.JSON(code, proto.PostPasteResp{
+			Code:    code,
+			Message: message,
 		})
 		return
 	}
 
+	c.Set(util.PASTEKEY, key)
+	recordAudit(ctx, c, log, p.Audit, db.AuditCreated, key, "")
+
 	// 返回成功响应
 	c.JSON(http.StatusCreated, proto.PostPasteResp{
 		Code: http.StatusCreated,
 		Key:  key,
+		URL:  shareURL(c, key),
 	})
 }
 
@@ -222,6 +366,19 @@ func (p *Paste) GetPaste(c *gin.Context) {
 		// 从URL路径参数获取key，从URL查询参数中获取password
 		key, password = c.Param("key"), c.Query("password")
 	)
+	c.Set(util.PASTEKEY, key)
+	// 错误响应不允许缓存，成功时由 setPasteCacheHeaders 覆盖
+	c.Header("Cache-Control", "no-store")
+
+	// 读取前先检查参数格式，避免格式错误时销毁一次性分享
+	selection, message := parseSnippetQuery(c)
+	if message != "" {
+		c.JSON(http.StatusOK, proto.GetPasteResp{
+			Code:    http.StatusBadRequest,
+			Message: message,
+		})
+		return
+	}
 
 	entry, err := p.Paste.Get(ctx, key, password)
 	if err != nil {
@@ -245,32 +402,206 @@ func (p *Paste) GetPaste(c *gin.Context) {
 		return
 	}
 
-	// 处理图片URL：如果是云存储，按需生成临时签名URL
-	if len(entry.Images) > 0 && entry.Images[0].StorageType == storage.StorageTypeCloud {
-		for i := range entry.Images {
-			objectKey := entry.Images[i].ObjectKey
+	// 一次性分享读取后即被销毁
+	if entry.Once {
+		recordAudit(ctx, c, log, p.Audit, db.AuditBurned, key, "")
+	} else {
+		recordAudit(ctx, c, log, p.Audit, db.AuditRead, key, "")
+	}
+
+	// 一次性分享已经销毁，忽略超出范围的选择并返回全部内容，避免内容丢失
+	if message := selection.validate(entry.Snippets); message != "" {
+		if !entry.Once {
+			c.JSON(http.StatusOK, proto.GetPasteResp{
+				Code:    http.StatusBadRequest,
+				Message: message,
+			})
+			return
+		}
+		selection = snippetSelection{}
+	}
+
+	// 客户端缓存仍然有效时直接返回 304，无需生成签名URL
+	setPasteCacheHeaders(c, entry)
+	if !entry.Once && notModified(c) {
+		c.Status(http.StatusNotModified)
+		return
+	}
+
+	// 处理附件URL：如果是云存储，按需生成临时签名URL
+	if len(entry.Attachments) > 0 && entry.Attachments[0].StorageType == storage.StorageTypeCloud {
+		for i := range entry.Attachments {
+			objectKey := entry.Attachments[i].ObjectKey
 			if objectKey != "" {
 				signedURL, err := storage.StorageConfig.OSS.GetSignedURL(ctx, objectKey)
 				if err != nil {
 					log.Warnf("为 objectKey '%s' 生成签名URL失败: %+v", objectKey, err)
-					entry.Images[i].URL = "" // 生成签名URL失败，设置为空
+					entry.Attachments[i].URL = "" // 生成签名URL失败，设置为空
 				} else {
-					entry.Images[i].URL = signedURL // 更新为签名URL
+					entry.Attachments[i].URL = signedURL // 更新为签名URL
 				}
 			} else {
-				entry.Images[i].URL = "" // ObjectKey 不存在
+				entry.Attachments[i].URL = "" // ObjectKey 不存在
+			}
+			if thumbnail := entry.Attachments[i].Thumbnail; thumbnail != nil && thumbnail.ObjectKey != "" {
+				signedURL, err := storage.StorageConfig.OSS.GetSignedURL(ctx, thumbnail.ObjectKey)
+				if err != nil {
+					log.Warnf("为缩略图 objectKey '%s' 生成签名URL失败: %+v", thumbnail.ObjectKey, err)
+				} else {
+					thumbnail.URL = signedURL
+				}
 			}
 		}
 	}
 
-	if entry.Images == nil {
-		entry.Images = []proto.ImageFile{}
+	// 图片和其他附件分开返回，旧客户端只识别 images
+	images, files := []proto.Attachment{}, []proto.Attachment(nil)
+	for i, attachment := range entry.Attachments {
+		if !entry.Once {
+			attachment.DownloadURL = fmt.Sprintf("/v1/paste/%s/attachments/%d", url.PathEscape(key), i)
+		}
+		if attachment.IsImage() {
+			images = append(images, attachment)
+			continue
+		}
+		// 一次性分享读取后即销毁，附件内容只能随响应返回，其他分享通过下载接口获取
+		if !entry.Once {
+			attachment.Base64Content = ""
+		}
+		files = append(files, attachment)
 	}
 
-	// 返回成功响应
+	// 返回成功响应，选择片段或行范围时只返回选中的内容
+	snippets, lines := selection.apply(entry.Snippets)
 	c.JSON(http.StatusOK, proto.GetPasteResp{
-		Code:     http.StatusOK,
-		Snippets: entry.Snippets,
-		Images:   entry.Images,
+		Code:        http.StatusOK,
+		Snippets:    snippets,
+		Images:      images,
+		Attachments: files,
+		Selection:   lines,
+	})
+}
+
+// 下载分享中的附件
+func (p *Paste) GetAttachment(c *gin.Context) {
+	var (
+		ctx, log      = util.EnsureWithLogger(c)
+		key, password = c.Param("key"), c.Query("password")
+	)
+	c.Set(util.PASTEKEY, key)
+	c.Header("Cache-Control", "no-store")
+
+	index, err := strconv.Atoi(c.Param("index"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, proto.AttachmentResp{
+			Code:    http.StatusNotFound,
+			Message: proto.ErrNotFound,
+		})
+		return
+	}
+
+	attachment, err := p.Paste.Attachment(ctx, key, password, index)
+	if err != nil {
+		switch {
+		case errors.Is(err, mongo.ErrNoDocuments):
+			c.JSON(http.StatusNotFound, proto.AttachmentResp{
+				Code:    http.StatusNotFound,
+				Message: proto.ErrNotFound,
+			})
+		case err.Error() == proto.ErrWrongPassword:
+			c.JSON(http.StatusUnauthorized, proto.AttachmentResp{
+				Code:    http.StatusUnauthorized,
+				Message: proto.ErrWrongPassword,
+			})
+		case err.Error() == proto.ErrContentExpired:
+			c.JSON(http.StatusGone, proto.AttachmentResp{
+				Code:    http.StatusGone,
+				Message: proto.ErrContentExpired,
+			})
+		default:
+			log.Errorf("获取附件失败: %+v", err)
+			c.JSON(http.StatusInternalServerError, proto.AttachmentResp{
+				Code:    http.StatusInternalServerError,
+				Message: proto.ErrQueryFailed,
+			})
+		}
+		return
+	}
+
+	body, err := storage.OpenAttachment(ctx, attachment)
+	if err != nil {
+		log.Errorf("读取附件 %s 失败: %+v", attachment.Filename, err)
+		c.JSON(http.StatusInternalServerError, proto.AttachmentResp{
+			Code:    http.StatusInternalServerError,
+			Message: proto.ErrQueryFailed,
+		})
+		return
+	}
+	defer body.Close()
+
+	name := attachment.Name
+	if name == "" {
+		name = attachment.Filename
+	}
+	// 总是作为附件下载，并禁止浏览器猜测类型，避免上传的文件在本站域名下被当作页面执行
+	c.Header("Content-Disposition", contentDisposition("attachment", name))
+	c.Header("X-Content-Type-Options", "nosniff")
+	c.Header("Content-Security-Policy", "default-src 'none'; sandbox")
+	if attachment.Hash != "" {
+		c.Header("ETag", fmt.Sprintf(`"%s"`, attachment.Hash))
+	}
+	c.DataFromReader(http.StatusOK, attachment.Size, attachment.ContentType, body, nil)
+}
+
+// 举报分享内容
+func (p *Paste) ReportPaste(c *gin.Context) {
+	var (
+		ctx, log = util.EnsureWithLogger(c)
+		key      = c.Param("key")
+		req      proto.ReportPasteReq
+	)
+	c.Set(util.PASTEKEY, key)
+
+	// 举报原因可选，请求体为空时忽略
+	if err := c.ShouldBindJSON(&req); err != nil && c.Request.ContentLength > 0 {
+		log.Errorf("绑定请求数据失败: %+v", err)
+		c.JSON(http.StatusBadRequest, proto.ReportPasteResp{
+			Code:    http.StatusBadRequest,
+			Message: proto.ErrInvalidArgs,
+		})
+		return
+	}
+	if utf8.RuneCountInString(req.Reason) > reportReasonMaxLength {
+		log.Errorf("举报原因过长: %d", utf8.RuneCountInString(req.Reason))
+		c.JSON(http.StatusBadRequest, proto.ReportPasteResp{
+			Code:    http.StatusBadRequest,
+			Message: fmt.Sprintf(proto.ErrTooManyContent, reportReasonMaxLength),
+		})
+		return
+	}
+
+	// 检查分享是否存在，不能使用 Get，否则会销毁一次性分享
+	exists, err := p.Paste.Exists(ctx, key)
+	if err != nil {
+		log.Errorf("查询分享内容失败: %+v", err)
+		c.JSON(http.StatusInternalServerError, proto.ReportPasteResp{
+			Code:    http.StatusInternalServerError,
+			Message: proto.ErrQueryFailed,
+		})
+		return
+	}
+	if !exists {
+		c.JSON(http.StatusNotFound, proto.ReportPasteResp{
+			Code:    http.StatusNotFound,
+			Message: proto.ErrNotFound,
+		})
+		return
+	}
+
+	recordAudit(ctx, c, log, p.Audit, db.AuditReported, key, req.Reason)
+	log.Infof("分享内容被举报: %s, 原因: %s", key, req.Reason)
+
+	c.JSON(http.StatusAccepted, proto.ReportPasteResp{
+		Code: http.StatusAccepted,
 	})
 }
@@ -0,0 +1,108 @@
+package service
+
+import (
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/skip2/go-qrcode"
+
+	"paste.org.cn/paste/server/proto"
+	"paste.org.cn/paste/server/util"
+)
+
+// 二维码图片的边长（像素）
+const (
+	qrDefaultSize = 256
+	qrMinSize     = 64
+	qrMaxSize     = 1024
+)
+
+// X-Forwarded-Prefix 只允许由路径段组成，例如 /api
+var forwardedPrefixPattern = regexp.MustCompile(`^(/[A-Za-z0-9._~-]+)*$`)
+
+// publicBaseURL 返回前端页面的根地址，优先使用 server.public_url，未配置时根据请求的 Host 和协议生成
+func publicBaseURL(c *gin.Context) string {
+	if base := util.GetConfig().Server.PublicURL; base != "" {
+		return strings.TrimSuffix(base, "/")
+	}
+	return requestBaseURL(c)
+}
+
+// requestBaseURL 根据请求的 Host 和协议（TLS 或 X-Forwarded-Proto）生成根地址
+func requestBaseURL(c *gin.Context) string {
+	scheme := "http"
+	if c.Request.TLS != nil || strings.EqualFold(c.GetHeader("X-Forwarded-Proto"), "https") {
+		scheme = "https"
+	}
+	return scheme + "://" + c.Request.Host
+}
+
+// shareURL 返回分享的访问链接
+func shareURL(c *gin.Context, key string) string {
+	return publicBaseURL(c) + "/" + url.PathEscape(key)
+}
+
+// apiURL 返回接口的绝对地址，反向代理通过 X-Forwarded-Prefix 传递去掉的路径前缀，例如 /api
+func apiURL(c *gin.Context, path string) string {
+	prefix := strings.TrimSuffix(c.GetHeader("X-Forwarded-Prefix"), "/")
+	if !forwardedPrefixPattern.MatchString(prefix) {
+		prefix = ""
+	}
+	return requestBaseURL(c) + prefix + path
+}
+
+// 生成分享链接的二维码
+func (p *Paste) GetQRCode(c *gin.Context) {
+	var (
+		ctx, log = util.EnsureWithLogger(c)
+		key      = c.Param("key")
+		size     = qrDefaultSize
+		err      error
+	)
+	c.Set(util.PASTEKEY, key)
+
+	if s := c.Query("size"); s != "" {
+		if size, err = strconv.Atoi(s); err != nil || size < qrMinSize || size > qrMaxSize {
+			c.JSON(http.StatusBadRequest, proto.QRCodeResp{
+				Code:    http.StatusBadRequest,
+				Message: proto.ErrInvalidArgs,
+			})
+			return
+		}
+	}
+
+	// 只检查分享是否存在，不能使用 Get，否则会销毁一次性分享
+	exists, err := p.Paste.Exists(ctx, key)
+	if err != nil {
+		log.Errorf("查询分享内容失败: %+v", err)
+		c.JSON(http.StatusInternalServerError, proto.QRCodeResp{
+			Code:    http.StatusInternalServerError,
+			Message: proto.ErrQueryFailed,
+		})
+		return
+	}
+	if !exists {
+		c.JSON(http.StatusNotFound, proto.QRCodeResp{
+			Code:    http.StatusNotFound,
+			Message: proto.ErrNotFound,
+		})
+		return
+	}
+
+	png, err := qrcode.Encode(shareURL(c, key), qrcode.Medium, size)
+	if err != nil {
+		log.Errorf("生成二维码失败: %+v", err)
+		c.JSON(http.StatusInternalServerError, proto.QRCodeResp{
+			Code:    http.StatusInternalServerError,
+			Message: proto.ErrQueryFailed,
+		})
+		return
+	}
+	// 二维码只包含链接，不包含分享内容，分享被删除后链接同样失效
+	c.Header("Cache-Control", "public, max-age=3600")
+	c.Data(http.StatusOK, "image/png", png)
+}
@@ -0,0 +1,332 @@
+package service
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"paste.org.cn/paste/server/db"
+	"paste.org.cn/paste/server/proto"
+	"paste.org.cn/paste/server/storage"
+	"paste.org.cn/paste/server/util"
+)
+
+// 支持的 tus 协议版本和扩展
+const (
+	tusVersion    = "1.0.0"
+	tusExtensions = "creation,expiration,termination"
+)
+
+// PATCH 请求要求的 Content-Type
+const tusContentType = "application/offset+octet-stream"
+
+// 每个 PATCH 请求写入的数据块大小，最后一块可以小于 minChunkSize
+// 限制单个请求占用连接和存储的时间，也避免一个上传被拆成大量很小的数据块
+const (
+	minChunkSize = 1 << 20
+	maxChunkSize = 8 << 20
+)
+
+// Upload 实现 tus 可续传上传协议，上传完成后在创建分享时通过 uploads 字段引用
+type Upload struct {
+	db.Upload
+}
+
+// 查询 tus 协议支持的版本、扩展和最大文件大小
+func (u *Upload) Options(c *gin.Context) {
+	c.Header("Tus-Resumable", tusVersion)
+	c.Header("Tus-Version", tusVersion)
+	c.Header("Tus-Extension", tusExtensions)
+	c.Header("Tus-Max-Size", strconv.FormatInt(maxUploadSize(), 10))
+	c.Status(http.StatusNoContent)
+}
+
+// 创建可续传上传
+func (u *Upload) Create(c *gin.Context) {
+	ctx, log := util.EnsureWithLogger(c)
+	if !checkTusResumable(c) {
+		return
+	}
+
+	length, err := strconv.ParseInt(c.GetHeader("Upload-Length"), 10, 64)
+	if err != nil || length <= 0 {
+		log.Errorf("Upload-Length 无效: %q", c.GetHeader("Upload-Length"))
+		tusError(c, http.StatusBadRequest, proto.ErrInvalidArgs)
+		return
+	}
+	if length > maxUploadSize() {
+		log.Errorf("上传文件太大: %d", length)
+		tusError(c, http.StatusRequestEntityTooLarge, proto.ErrOverMaxSize, maxUploadSize()>>20)
+		return
+	}
+	metadata, err := parseUploadMetadata(c.GetHeader("Upload-Metadata"))
+	if err != nil {
+		log.Errorf("Upload-Metadata 无效: %+v", err)
+		tusError(c, http.StatusBadRequest, proto.ErrInvalidArgs)
+		return
+	}
+
+	now := time.Now()
+	entry := db.UploadEntry{
+		ID:        storage.NewUploadID(),
+		Length:    length,
+		Metadata:  metadata,
+		ClientIP:  c.ClientIP(),
+		CreatedAt: now,
+		ExpireAt:  now.Add(time.Duration(util.GetConfig().Upload.Expire) * time.Hour),
+	}
+	if err := u.Upload.Create(ctx, entry); err != nil {
+		log.Errorf("创建上传失败: %+v", err)
+		tusError(c, http.StatusInternalServerError, proto.ErrUploadFailed)
+		return
+	}
+
+	c.Header("Location", "/v1/uploads/"+entry.ID)
+	c.Header("Upload-Expires", entry.ExpireAt.UTC().Format(http.TimeFormat))
+	c.Status(http.StatusCreated)
+}
+
+// 查询上传进度
+func (u *Upload) Head(c *gin.Context) {
+	ctx, log := util.EnsureWithLogger(c)
+	c.Header("Cache-Control", "no-store")
+	if !checkTusResumable(c) {
+		return
+	}
+
+	entry, err := u.Upload.Get(ctx, c.Param("id"))
+	if err != nil {
+		if !errors.Is(err, mongo.ErrNoDocuments) {
+			log.Errorf("查询上传失败: %+v", err)
+			c.Status(http.StatusInternalServerError)
+			return
+		}
+		c.Status(http.StatusNotFound)
+		return
+	}
+
+	c.Header("Upload-Offset", strconv.FormatInt(entry.Offset, 10))
+	c.Header("Upload-Length", strconv.FormatInt(entry.Length, 10))
+	c.Header("Upload-Expires", entry.ExpireAt.UTC().Format(http.TimeFormat))
+	c.Status(http.StatusOK)
+}
+
+// 从指定偏移量继续上传
+func (u *Upload) Patch(c *gin.Context) {
+	ctx, log := util.EnsureWithLogger(c)
+	if !checkTusResumable(c) {
+		return
+	}
+	if c.ContentType() != tusContentType {
+		tusError(c, http.StatusUnsupportedMediaType, proto.ErrInvalidArgs)
+		return
+	}
+	offset, err := strconv.ParseInt(c.GetHeader("Upload-Offset"), 10, 64)
+	if err != nil || offset < 0 {
+		tusError(c, http.StatusBadRequest, proto.ErrInvalidArgs)
+		return
+	}
+
+	id := c.Param("id")
+	entry, err := u.Upload.Get(ctx, id)
+	if err != nil {
+		if !errors.Is(err, mongo.ErrNoDocuments) {
+			log.Errorf("查询上传失败: %+v", err)
+			tusError(c, http.StatusInternalServerError, proto.ErrUploadFailed)
+			return
+		}
+		tusError(c, http.StatusNotFound, proto.ErrUploadNotFound)
+		return
+	}
+	if offset != entry.Offset {
+		log.Errorf("上传 %s 偏移量不一致: 请求 %d，实际 %d", id, offset, entry.Offset)
+		tusError(c, http.StatusConflict, proto.ErrInvalidArgs)
+		return
+	}
+
+	// 每个请求最多写入 maxChunkSize 字节，也不能超过剩余的字节数
+	remaining := entry.Length - entry.Offset
+	limit := min(remaining, maxChunkSize)
+	if length := c.Request.ContentLength; length > limit || (length > 0 && length < minChunkSize && length < remaining) {
+		log.Errorf("上传 %s 的数据块大小无效: %d，剩余 %d", id, length, remaining)
+		rejectChunk(c, length, remaining)
+		return
+	}
+
+	// 请求体边读取边写入数据块，客户端断开时请求的 ctx 已取消，保存数据不能使用该 ctx
+	ctx = context.WithoutCancel(ctx)
+	body := &chunkReader{r: c.Request.Body, limit: limit}
+	part, err := storage.StoreUploadPart(ctx, u.Upload, id, offset, body)
+	if err != nil {
+		storage.RemoveUploadParts(ctx, u.Upload, []db.UploadPart{part})
+		log.Errorf("保存上传数据块失败: %+v", err)
+		tusError(c, http.StatusInternalServerError, proto.ErrUploadFailed)
+		return
+	}
+	if body.err != nil {
+		// 连接中断时保存已经收到的数据，客户端通过 HEAD 获取新的偏移量后继续上传
+		log.Warnf("上传 %s 读取请求体中断，已收到 %d 字节: %+v", id, part.Size, body.err)
+	}
+	switch {
+	case body.err == nil && body.overflow():
+		// 没有 Content-Length 的请求超过了限制，多出的数据说明客户端有误，整块拒绝
+		storage.RemoveUploadParts(ctx, u.Upload, []db.UploadPart{part})
+		log.Errorf("上传 %s 的数据块超过 %d 字节", id, limit)
+		rejectChunk(c, limit+1, remaining)
+		return
+	case part.Size < minChunkSize && part.Size < remaining:
+		// 空的或太小的数据块不保存，避免一个上传产生大量数据块；中断时客户端从原来的偏移量重新上传
+		storage.RemoveUploadParts(ctx, u.Upload, []db.UploadPart{part})
+		if body.err == nil && part.Size > 0 {
+			log.Errorf("上传 %s 的数据块太小: %d", id, part.Size)
+			rejectChunk(c, part.Size, remaining)
+			return
+		}
+		part.Size = 0
+	}
+
+	if part.Size > 0 {
+		ok, err := u.Upload.Append(ctx, id, part)
+		if err != nil || !ok {
+			// 并发的请求已经写入了相同的偏移量，或者上传已过期
+			storage.RemoveUploadParts(ctx, u.Upload, []db.UploadPart{part})
+			if err != nil {
+				log.Errorf("更新上传偏移量失败: %+v", err)
+				tusError(c, http.StatusInternalServerError, proto.ErrUploadFailed)
+				return
+			}
+			tusError(c, http.StatusConflict, proto.ErrInvalidArgs)
+			return
+		}
+		offset += part.Size
+	}
+
+	c.Header("Upload-Offset", strconv.FormatInt(offset, 10))
+	c.Header("Upload-Expires", entry.ExpireAt.UTC().Format(http.TimeFormat))
+	c.Status(http.StatusNoContent)
+}
+
+// 终止上传并删除已上传的数据
+func (u *Upload) Delete(c *gin.Context) {
+	ctx, log := util.EnsureWithLogger(c)
+	if !checkTusResumable(c) {
+		return
+	}
+
+	entry, err := u.Upload.Delete(ctx, c.Param("id"))
+	if err != nil {
+		if !errors.Is(err, mongo.ErrNoDocuments) {
+			log.Errorf("删除上传失败: %+v", err)
+			tusError(c, http.StatusInternalServerError, proto.ErrDeleteFailed)
+			return
+		}
+		tusError(c, http.StatusNotFound, proto.ErrUploadNotFound)
+		return
+	}
+	storage.RemoveUploadParts(ctx, u.Upload, entry.Parts)
+	c.Status(http.StatusNoContent)
+}
+
+// maxUploadSize 返回允许上传的最大文件大小（字节），取图片和附件大小限制中较大的一个
+// 引用上传时再按识别出的类型检查
+func maxUploadSize() int64 {
+	return int64(max(util.LimitConfig.ImagesSize(), util.LimitConfig.FilesSize())) << 20
+}
+
+// chunkReader 读取 PATCH 请求体，最多读取 limit 字节
+// 读取出错（例如连接中断）时按 EOF 结束，已经收到的数据照常保存，错误保存在 err 中
+type chunkReader struct {
+	r     io.Reader
+	limit int64
+	n     int64
+	err   error
+}
+
+func (r *chunkReader) Read(p []byte) (int, error) {
+	if r.n >= r.limit {
+		return 0, io.EOF
+	}
+	if int64(len(p)) > r.limit-r.n {
+		p = p[:r.limit-r.n]
+	}
+	n, err := r.r.Read(p)
+	r.n += int64(n)
+	if err != nil && err != io.EOF {
+		r.err = err
+		err = io.EOF
+	}
+	return n, err
+}
+
+// overflow 在读取了 limit 字节后检查请求体是否还有更多数据
+func (r *chunkReader) overflow() bool {
+	if r.n < r.limit {
+		return false
+	}
+	n, _ := r.r.Read(make([]byte, 1))
+	return n > 0
+}
+
+// rejectChunk 返回数据块大小无效的错误，超过 Upload-Length 或单个数据块的上限返回 413，太小返回 400
+func rejectChunk(c *gin.Context, size, remaining int64) {
+	switch {
+	case size > remaining:
+		tusError(c, http.StatusRequestEntityTooLarge, proto.ErrOverMaxSize, maxUploadSize()>>20)
+	case size > maxChunkSize:
+		tusError(c, http.StatusRequestEntityTooLarge, proto.ErrUploadChunkSize, minChunkSize>>20, maxChunkSize>>20)
+	default:
+		tusError(c, http.StatusBadRequest, proto.ErrUploadChunkSize, minChunkSize>>20, maxChunkSize>>20)
+	}
+}
+
+// checkTusResumable 设置 Tus-Resumable 响应头并检查客户端的协议版本，不支持时返回 412
+func checkTusResumable(c *gin.Context) bool {
+	c.Header("Tus-Resumable", tusVersion)
+	if c.GetHeader("Tus-Resumable") != tusVersion {
+		c.Header("Tus-Version", tusVersion)
+		c.AbortWithStatus(http.StatusPreconditionFailed)
+		return false
+	}
+	return true
+}
+
+// tusError 返回错误响应，format 和 args 用于生成错误信息
+func tusError(c *gin.Context, code int, format string, args ...any) {
+	message := format
+	if len(args) > 0 {
+		message = fmt.Sprintf(format, args...)
+	}
+	c.JSON(code, proto.UploadResp{
+		Code:    code,
+		Message: message,
+	})
+}
+
+// parseUploadMetadata 解析 Upload-Metadata 请求头，格式为逗号分隔的 "key base64(value)"，value 可以省略
+func parseUploadMetadata(header string) (map[string]string, error) {
+	metadata := make(map[string]string)
+	if header == "" {
+		return metadata, nil
+	}
+	for _, pair := range strings.Split(header, ",") {
+		key, encoded, _ := strings.Cut(strings.TrimSpace(pair), " ")
+		if key == "" {
+			return nil, errors.New("empty metadata key")
+		}
+		value, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, err
+		}
+		metadata[key] = string(value)
+	}
+	return metadata, nil
+}
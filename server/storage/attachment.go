@@ -0,0 +1,267 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	log "github.com/sirupsen/logrus"
+
+	"paste.org.cn/paste/server/db"
+	"paste.org.cn/paste/server/imaging"
+	"paste.org.cn/paste/server/metrics"
+	"paste.org.cn/paste/server/proto"
+	"paste.org.cn/paste/server/util"
+)
+
+// 附件文件名的最大长度（字符）
+const maxAttachmentNameLength = 200
+
+// 允许上传的文本附件，按扩展名区分，内容必须是不含 NUL 的 UTF-8 文本
+var textAttachmentTypes = map[string]string{
+	".txt":  "text/plain; charset=utf-8",
+	".log":  "text/plain; charset=utf-8",
+	".json": "application/json",
+	".har":  "application/json",
+}
+
+// 识别附件类型时读取的文件开头长度，需要覆盖 tar 头部中偏移 257 的魔数
+const sniffLength = 512
+
+// sniffAttachment 根据文件开头的内容识别图片以外的附件类型，返回 MIME 类型、对象名的扩展名和是否为文本类型
+// 二进制格式只看魔数，不信任客户端提供的 Content-Type；文本格式按文件扩展名区分，内容由调用方用 validText 校验
+// 不在允许列表中的类型返回 proto.ErrAttachmentType
+func sniffAttachment(filename string, head []byte) (contentType, ext string, text bool, err error) {
+	switch {
+	case bytes.HasPrefix(head, []byte("%PDF-")):
+		return "application/pdf", ".pdf", false, nil
+	case bytes.HasPrefix(head, []byte("PK\x03\x04")), bytes.HasPrefix(head, []byte("PK\x05\x06")):
+		return "application/zip", ".zip", false, nil
+	case bytes.HasPrefix(head, []byte{0x1F, 0x8B}):
+		return "application/gzip", ".gz", false, nil
+	case len(head) >= 262 && string(head[257:262]) == "ustar":
+		return "application/x-tar", ".tar", false, nil
+	}
+
+	ext = strings.ToLower(path.Ext(filename))
+	if contentType, ok := textAttachmentTypes[ext]; ok {
+		return contentType, ext, true, nil
+	}
+	return "", "", false, errors.New(proto.ErrAttachmentType)
+}
+
+// validText 检查文本附件的内容是否为不含 NUL 的 UTF-8
+func validText(data []byte) bool {
+	return utf8.Valid(data) && bytes.IndexByte(data, 0) < 0
+}
+
+// detectAttachment 根据完整的文件内容识别图片以外的附件类型，返回 MIME 类型和对象名的扩展名
+func detectAttachment(filename string, data []byte) (contentType, ext string, err error) {
+	contentType, ext, text, err := sniffAttachment(filename, data)
+	if err == nil && text && !validText(data) {
+		return "", "", errors.New(proto.ErrAttachmentType)
+	}
+	return contentType, ext, err
+}
+
+// attachmentChecker 在流式上传时统计附件大小，文本附件同时校验内容，超过上限或内容无效时写入返回错误，中止上传
+type attachmentChecker struct {
+	text    bool
+	limit   int // 大小上限（MB）
+	size    int64
+	pending []byte // 上一次写入末尾不完整的 UTF-8 字符
+	err     error  // 返回给客户端的错误
+}
+
+func (w *attachmentChecker) Write(p []byte) (int, error) {
+	if w.size += int64(len(p)); w.size > int64(w.limit)<<20 {
+		w.err = fmt.Errorf(proto.ErrOverMaxSize, w.limit)
+		return 0, w.err
+	}
+	if !w.text {
+		return len(p), nil
+	}
+
+	data := p
+	if len(w.pending) > 0 {
+		data = append(w.pending, p...)
+	}
+	// 末尾不完整的字符留到下一次写入时一起校验
+	cut := len(data)
+	for i := len(data) - 1; i >= 0 && i > len(data)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(data[i]) {
+			if !utf8.FullRune(data[i:]) {
+				cut = i
+			}
+			break
+		}
+	}
+	if !validText(data[:cut]) {
+		w.err = errors.New(proto.ErrAttachmentType)
+		return 0, w.err
+	}
+	w.pending = append([]byte(nil), data[cut:]...)
+	return len(p), nil
+}
+
+// finish 在全部内容写入后调用，文本附件不能以不完整的字符结尾
+func (w *attachmentChecker) finish() error {
+	if w.text && len(w.pending) > 0 {
+		w.err = errors.New(proto.ErrAttachmentType)
+	}
+	return w.err
+}
+
+// attachmentName 清理客户端提供的文件名，只保留最后一级路径，去掉控制字符并限制长度
+func attachmentName(filename string) string {
+	filename = path.Base(strings.ReplaceAll(filename, `\`, "/"))
+	if filename == "." || filename == "/" {
+		return ""
+	}
+	name := strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) || r == utf8.RuneError {
+			return -1
+		}
+		return r
+	}, filename)
+	if runes := []rune(name); len(runes) > maxAttachmentNameLength {
+		name = string(runes[:maxAttachmentNameLength])
+	}
+	return strings.TrimSpace(name)
+}
+
+// saveAttachment 识别并保存单个附件，图片按 saveImage 处理并生成缩略图，其他允许的文件按原样保存
+func saveAttachment(ctx context.Context, log *log.Entry, blobs db.Blob, filename, contentType string, data []byte) (proto.Attachment, error) {
+	if _, err := imaging.Detect(data); err == nil {
+		if int64(len(data)) > int64(util.LimitConfig.ImagesSize())<<20 {
+			log.Errorf("图片 '%s' 太大，超过 %d MB", filename, util.LimitConfig.ImagesSize())
+			return proto.Attachment{}, fmt.Errorf(proto.ErrOverMaxSize, util.LimitConfig.ImagesSize())
+		}
+		return saveImage(ctx, log, blobs, filename, contentType, data)
+	}
+
+	detected, ext, err := detectAttachment(filename, data)
+	if err != nil {
+		log.Errorf("附件 '%s' (Content-Type: %s) 类型不允许", filename, contentType)
+		return proto.Attachment{}, err
+	}
+	if int64(len(data)) > int64(util.LimitConfig.FilesSize())<<20 {
+		log.Errorf("附件 '%s' 太大，超过 %d MB", filename, util.LimitConfig.FilesSize())
+		return proto.Attachment{}, fmt.Errorf(proto.ErrOverMaxSize, util.LimitConfig.FilesSize())
+	}
+
+	file := proto.Attachment{
+		Kind:        proto.AttachmentKindFile,
+		Name:        attachmentName(filename),
+		Filename:    fmt.Sprintf("%d_%s%s", time.Now().UnixNano(), uuid.NewString(), ext),
+		Size:        int64(len(data)),
+		ContentType: detected,
+	}
+	file.Hash, file.Base64Content, file.ObjectKey, err = storeBlob(ctx, blobs, file.ContentType, ext, data)
+	if err != nil {
+		log.Errorf("保存附件失败: %+v", err)
+		return proto.Attachment{}, err
+	}
+	metrics.FileBytes.WithLabelValues(StorageConfig.Type).Add(float64(file.Size))
+
+	// 保存成功后才设置存储类型，UploadAttachments 据此判断哪些附件需要在失败时释放
+	file.StorageType = StorageConfig.Type
+	return file, nil
+}
+
+// streamAttachment 把图片以外的附件从请求体直接上传到云存储，不在内存中缓存完整文件
+// 上传的同时计算哈希、检查大小和文本内容，上传前还不知道哈希，先写入新的对象，完成后再按哈希登记去重
+func streamAttachment(ctx context.Context, log *log.Entry, blobs db.Blob, filename, contentType string, body *bufio.Reader) (proto.Attachment, error) {
+	head, _ := body.Peek(sniffLength)
+	detected, ext, text, err := sniffAttachment(filename, head)
+	if err != nil {
+		log.Errorf("附件 '%s' (Content-Type: %s) 类型不允许", filename, contentType)
+		return proto.Attachment{}, err
+	}
+
+	hasher := sha256.New()
+	checker := &attachmentChecker{text: text, limit: util.LimitConfig.FilesSize()}
+	objectKey := db.NewStreamObjectKey(ext)
+	err = StorageConfig.OSS.Upload(ctx, io.TeeReader(body, io.MultiWriter(checker, hasher)), UploadOptions{
+		ObjectKey:   objectKey,
+		ContentType: detected,
+	})
+	if err == nil {
+		err = checker.finish()
+	}
+	if err != nil {
+		deleteObject(context.WithoutCancel(ctx), objectKey)
+		if checker.err != nil {
+			log.Errorf("附件 '%s' 太大或内容无效: %v", filename, checker.err)
+			return proto.Attachment{}, checker.err
+		}
+		log.Errorf("上传附件到云存储失败: %+v", err)
+		return proto.Attachment{}, err
+	}
+
+	file := proto.Attachment{
+		Kind:        proto.AttachmentKindFile,
+		Name:        attachmentName(filename),
+		Filename:    fmt.Sprintf("%d_%s%s", time.Now().UnixNano(), uuid.NewString(), ext),
+		Size:        checker.size,
+		ContentType: detected,
+		Hash:        hex.EncodeToString(hasher.Sum(nil)),
+	}
+	file.ObjectKey, err = acquireUploadedBlob(ctx, blobs, file.Hash, objectKey, detected, file.Size)
+	if err != nil {
+		log.Errorf("保存附件失败: %+v", err)
+		return proto.Attachment{}, err
+	}
+	metrics.FileBytes.WithLabelValues(StorageConfig.Type).Add(float64(file.Size))
+
+	file.StorageType = StorageConfig.Type
+	return file, nil
+}
+
+// OpenAttachment 读取附件内容，base64 存储直接解码，云存储从对象存储下载
+func OpenAttachment(ctx context.Context, attachment proto.Attachment) (io.ReadCloser, error) {
+	if attachment.StorageType != StorageTypeCloud {
+		data, err := base64.StdEncoding.DecodeString(attachment.Base64Content)
+		if err != nil {
+			return nil, err
+		}
+		return io.NopCloser(bytes.NewReader(data)), nil
+	}
+	if StorageConfig.OSS == nil {
+		return nil, errors.New("cloud storage is not available")
+	}
+	return StorageConfig.OSS.Download(ctx, attachment.ObjectKey)
+}
+
+// checkAttachmentCounts 按识别出的类型分别检查图片和其他附件的数量
+func checkAttachmentCounts(log *log.Entry, attachments []proto.Attachment) error {
+	var images, files int
+	for _, attachment := range attachments {
+		if attachment.IsImage() {
+			images++
+		} else {
+			files++
+		}
+	}
+	if images > util.LimitConfig.ImagesCount() {
+		log.Errorf("图片数量过多: %d", images)
+		return fmt.Errorf(proto.ErrTooManyCount, util.LimitConfig.ImagesCount())
+	}
+	if files > util.LimitConfig.FilesCount() {
+		log.Errorf("附件数量过多: %d", files)
+		return fmt.Errorf(proto.ErrTooManyCount, util.LimitConfig.FilesCount())
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package storage
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestAttachmentChecker(t *testing.T) {
+	tests := []struct {
+		name    string
+		text    bool
+		data    []byte
+		wantErr bool
+	}{
+		{"文本", true, []byte(strings.Repeat("中文ab", 1000)), false},
+		{"二进制允许 NUL", false, []byte("PK\x03\x04\x00\xFF"), false},
+		{"包含 NUL", true, []byte("{\x00}"), true},
+		{"无效 UTF-8", true, []byte("ab\xFFcd"), true},
+		{"以不完整的字符结尾", true, []byte("abc\xE4\xB8"), true},
+		{"超过大小上限", false, bytes.Repeat([]byte("a"), 1<<20+1), true},
+	}
+	for _, tt := range tests {
+		// 按不同的块大小写入，覆盖字符被拆分到两次写入的情况
+		for _, chunk := range []int{1, 2, 3, 7, 4096} {
+			checker := &attachmentChecker{text: tt.text, limit: 1}
+			var err error
+			for i := 0; i < len(tt.data) && err == nil; i += chunk {
+				_, err = checker.Write(tt.data[i:min(i+chunk, len(tt.data))])
+			}
+			if err == nil {
+				err = checker.finish()
+			}
+			if (err != nil) != tt.wantErr {
+				t.Errorf("%s (chunk %d): error = %v, wantErr %v", tt.name, chunk, err, tt.wantErr)
+			}
+		}
+	}
+}
+
+func TestSniffAttachment(t *testing.T) {
+	tar := make([]byte, 512)
+	copy(tar[257:], "ustar")
+	tests := []struct {
+		filename string
+		head     []byte
+		want     string
+		text     bool
+		wantErr  bool
+	}{
+		{"a.bin", []byte("%PDF-1.7"), "application/pdf", false, false},
+		{"a.zip", []byte("PK\x03\x04"), "application/zip", false, false},
+		{"a", []byte{0x1F, 0x8B, 0x08}, "application/gzip", false, false},
+		{"a", tar, "application/x-tar", false, false},
+		{"A.LOG", []byte("hello"), "text/plain; charset=utf-8", true, false},
+		{"a.har", []byte("{}"), "application/json", true, false},
+		{"a.exe", []byte("MZ"), "", false, true},
+	}
+	for _, tt := range tests {
+		got, _, text, err := sniffAttachment(tt.filename, tt.head)
+		if (err != nil) != tt.wantErr || got != tt.want || text != tt.text {
+			t.Errorf("sniffAttachment(%q) = %q, %v, %v, want %q, %v", tt.filename, got, text, err, tt.want, tt.text)
+		}
+	}
+}
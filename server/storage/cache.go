@@ -0,0 +1,44 @@
+package storage
+
+import (
+	"context"
+	"time"
+
+	"paste.org.cn/paste/server/cache"
+	"paste.org.cn/paste/server/metrics"
+)
+
+// 签名URL缓存 key 的前缀
+const signedURLCachePrefix = "signed_url:"
+
+// 签名URL在过期前提前失效的时间，保证返回给客户端的URL仍有足够的有效期
+const signedURLExpireMargin = time.Minute
+
+// cachedOSS 包装 OSS，缓存生成的签名URL，避免每次读取都重新签名
+type cachedOSS struct {
+	OSS
+	cache cache.Cache
+}
+
+func (o cachedOSS) GetSignedURL(ctx context.Context, objectKey string) (string, error) {
+	if url, ok := o.cache.Get(ctx, signedURLCachePrefix+objectKey); ok {
+		metrics.CacheLookup("signed_url", true)
+		return string(url), nil
+	}
+	metrics.CacheLookup("signed_url", false)
+
+	// 在签名前读取有效期，避免热更新导致缓存时间超过URL的实际有效期
+	ttl := o.OSS.URLExpire() - signedURLExpireMargin
+	url, err := o.OSS.GetSignedURL(ctx, objectKey)
+	if err != nil {
+		return "", err
+	}
+	o.cache.Set(ctx, signedURLCachePrefix+objectKey, []byte(url), ttl)
+	return url, nil
+}
+
+// Delete 删除对象时同时删除缓存的签名URL
+func (o cachedOSS) Delete(ctx context.Context, objectKey string) error {
+	o.cache.Delete(ctx, signedURLCachePrefix+objectKey)
+	return o.OSS.Delete(ctx, objectKey)
+}
@@ -0,0 +1,75 @@
+package storage
+
+import (
+	"context"
+	"io"
+
+	"go.opentelemetry.io/otel/attribute"
+
+	"paste.org.cn/paste/server/metrics"
+	"paste.org.cn/paste/server/tracing"
+)
+
+// instrumentedOSS 包装 OSS，为上传、下载、删除和签名记录链路追踪和失败指标
+type instrumentedOSS struct {
+	OSS
+}
+
+func (o instrumentedOSS) Upload(ctx context.Context, content io.Reader, opts UploadOptions) error {
+	ctx, span := tracing.Start(ctx, "storage.OSS.Upload",
+		attribute.String("storage.object_key", opts.ObjectKey),
+		attribute.String("storage.content_type", opts.ContentType),
+	)
+	err := o.OSS.Upload(ctx, content, opts)
+	if err != nil {
+		metrics.OSSFailures.WithLabelValues("upload").Inc()
+	}
+	tracing.End(span, err)
+	return err
+}
+
+func (o instrumentedOSS) Download(ctx context.Context, objectKey string) (io.ReadCloser, error) {
+	ctx, span := tracing.Start(ctx, "storage.OSS.Download",
+		attribute.String("storage.object_key", objectKey),
+	)
+	body, err := o.OSS.Download(ctx, objectKey)
+	if err != nil {
+		metrics.OSSFailures.WithLabelValues("download").Inc()
+	}
+	tracing.End(span, err)
+	return body, err
+}
+
+func (o instrumentedOSS) Delete(ctx context.Context, objectKey string) error {
+	ctx, span := tracing.Start(ctx, "storage.OSS.Delete",
+		attribute.String("storage.object_key", objectKey),
+	)
+	err := o.OSS.Delete(ctx, objectKey)
+	if err != nil {
+		metrics.OSSFailures.WithLabelValues("delete").Inc()
+	}
+	tracing.End(span, err)
+	return err
+}
+
+func (o instrumentedOSS) GetSignedURL(ctx context.Context, objectKey string) (string, error) {
+	ctx, span := tracing.Start(ctx, "storage.OSS.GetSignedURL",
+		attribute.String("storage.object_key", objectKey),
+	)
+	url, err := o.OSS.GetSignedURL(ctx, objectKey)
+	if err != nil {
+		metrics.OSSFailures.WithLabelValues("sign").Inc()
+	}
+	tracing.End(span, err)
+	return url, err
+}
+
+func (o instrumentedOSS) Ping(ctx context.Context) error {
+	ctx, span := tracing.Start(ctx, "storage.OSS.Ping")
+	err := o.OSS.Ping(ctx)
+	if err != nil {
+		metrics.OSSFailures.WithLabelValues("ping").Inc()
+	}
+	tracing.End(span, err)
+	return err
+}
@@ -4,6 +4,9 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"time"
+
+	"paste.org.cn/paste/server/util"
 )
 
 const (
@@ -22,14 +25,19 @@ type UploadOptions struct {
 
 type OSS interface {
 	Upload(ctx context.Context, content io.Reader, opts UploadOptions) error
+	Download(ctx context.Context, objectKey string) (io.ReadCloser, error)
+	Delete(ctx context.Context, objectKey string) error
 	SetLifeCycle(ctx context.Context) error
 	GetSignedURL(ctx context.Context, objectKey string) (string, error)
+	URLExpire() time.Duration       // 签名URL有效期
+	SetURLExpire(d time.Duration)   // 更新签名URL有效期，用于配置热更新
+	Ping(ctx context.Context) error // 检查存储桶是否可访问，用于就绪检查
 }
 
-func NewOSSWithFactory(provider string) (OSS, error) {
-	switch provider {
+func NewOSSWithFactory(config util.CloudConfig) (OSS, error) {
+	switch config.Provider {
 	case ProviderTencent:
-		oss, err := NewTencentOSS()
+		oss, err := NewTencentOSS(config)
 		if err != nil {
 			return nil, err
 		}
@@ -37,8 +45,8 @@ func NewOSSWithFactory(provider string) (OSS, error) {
 		if err != nil {
 			return nil, err
 		}
-		return oss, nil
+		return instrumentedOSS{OSS: oss}, nil
 	default:
-		return nil, fmt.Errorf("不支持的云存储提供商: %s", provider)
+		return nil, fmt.Errorf("不支持的云存储提供商: %s", config.Provider)
 	}
 }
@@ -0,0 +1,163 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	log "github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"paste.org.cn/paste/server/db"
+	"paste.org.cn/paste/server/proto"
+	"paste.org.cn/paste/server/util"
+)
+
+// 可续传上传的数据块在云存储中的前缀
+const uploadPrefix = "uploads/"
+
+// StoreUploadPart 按当前存储类型保存可续传上传的一个数据块，边读取 r 边写入，不在内存中缓存
+// 云存储保存为独立的对象，base64 存储保存到 GridFS，返回的 Size 为实际写入的字节数
+func StoreUploadPart(ctx context.Context, uploads db.Upload, id string, offset int64, r io.Reader) (db.UploadPart, error) {
+	part := db.UploadPart{Offset: offset}
+	counter := &countingReader{Reader: r}
+	var err error
+	if StorageConfig.Type != StorageTypeCloud {
+		part.FileID, err = uploads.PutPart(ctx, counter)
+	} else {
+		// 同一偏移量可能被并发写入，对象名加上随机后缀避免互相覆盖
+		part.ObjectKey = fmt.Sprintf("%s%s/%d-%s", uploadPrefix, id, offset, uuid.NewString()[:8])
+		err = StorageConfig.OSS.Upload(ctx, counter, UploadOptions{
+			ObjectKey:   part.ObjectKey,
+			ContentType: "application/octet-stream",
+		})
+	}
+	part.Size = counter.n
+	return part, err
+}
+
+// countingReader 统计实际读取的字节数
+type countingReader struct {
+	io.Reader
+	n int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	r.n += int64(n)
+	return n, err
+}
+
+// RemoveUploadParts 删除数据块，失败只记录日志，云存储中遗留的对象由生命周期规则删除
+func RemoveUploadParts(ctx context.Context, uploads db.Upload, parts []db.UploadPart) {
+	for _, part := range parts {
+		var err error
+		switch {
+		case part.ObjectKey != "":
+			if StorageConfig.OSS != nil {
+				err = StorageConfig.OSS.Delete(ctx, part.ObjectKey)
+			}
+		case part.FileID != "":
+			err = uploads.RemovePart(ctx, part.FileID)
+		}
+		if err != nil {
+			log.Warnf("删除上传数据块失败: %+v", err)
+		}
+	}
+}
+
+// readUpload 按顺序读取并拼接上传的所有数据块
+func readUpload(ctx context.Context, uploads db.Upload, entry db.UploadEntry) ([]byte, error) {
+	buf := bytes.NewBuffer(make([]byte, 0, entry.Length))
+	for _, part := range entry.Parts {
+		if part.FileID != "" {
+			if err := uploads.ReadPart(ctx, part.FileID, buf); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if StorageConfig.OSS == nil {
+			return nil, errors.New("cloud storage is not available")
+		}
+		body, err := StorageConfig.OSS.Download(ctx, part.ObjectKey)
+		if err != nil {
+			return nil, err
+		}
+		_, err = io.Copy(buf, body)
+		body.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	if int64(buf.Len()) != entry.Length {
+		return nil, fmt.Errorf("upload %s is corrupted: expected %d bytes, got %d", entry.ID, entry.Length, buf.Len())
+	}
+	return buf.Bytes(), nil
+}
+
+// ClaimUploads 取出已完成的可续传上传，按识别出的类型作为图片或附件处理并保存，existing 为请求中已上传的附件
+// 被引用的上传会立即删除，即使后续创建分享失败也不能再次引用
+// 返回错误时本次保存的附件引用会被释放
+func ClaimUploads(c *gin.Context, log *log.Entry, uploads db.Upload, blobs db.Blob, ids []string, existing []proto.Attachment) (attachments []proto.Attachment, err error) {
+	if limit := util.LimitConfig.ImagesCount() + util.LimitConfig.FilesCount(); len(existing)+len(ids) > limit {
+		log.Errorf("附件数量过多: %d", len(existing)+len(ids))
+		return nil, fmt.Errorf(proto.ErrTooManyCount, limit)
+	}
+
+	ctx := c.Request.Context()
+	defer func() {
+		if err != nil {
+			ReleaseAttachments(ctx, blobs, attachments)
+			attachments = nil
+		}
+	}()
+
+	for _, id := range ids {
+		entry, err := uploads.Claim(ctx, id)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			log.Errorf("上传 %s 不存在、未完成或已过期", id)
+			return attachments, errors.New(proto.ErrUploadNotFound)
+		}
+		if err != nil {
+			return attachments, err
+		}
+
+		data, err := readUpload(ctx, uploads, entry)
+		RemoveUploadParts(context.WithoutCancel(ctx), uploads, entry.Parts)
+		if err != nil {
+			log.Errorf("读取上传 %s 失败: %+v", id, err)
+			return attachments, err
+		}
+
+		attachment, err := saveAttachment(ctx, log, blobs, entry.Metadata["filename"], entry.Metadata["filetype"], data)
+		if err != nil {
+			return attachments, err
+		}
+		attachments = append(attachments, attachment)
+	}
+	return attachments, checkAttachmentCounts(log, append(slices.Clip(existing), attachments...))
+}
+
+// UploadCleanTask 返回删除过期可续传上传的清理任务
+func UploadCleanTask(uploads db.Upload) db.CleanTask {
+	return db.CleanTask{
+		Name: "过期的可续传上传",
+		Clean: func(ctx context.Context) (int64, error) {
+			return uploads.Expire(ctx, func(entry db.UploadEntry) {
+				RemoveUploadParts(ctx, uploads, entry.Parts)
+			})
+		},
+	}
+}
+
+// NewUploadID 生成可续传上传的 ID，使用随机 UUID 的十六进制表示，不可猜测
+func NewUploadID() string {
+	id := uuid.New()
+	return hex.EncodeToString(id[:])
+}
@@ -1,22 +1,22 @@
 package storage
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
 	"encoding/base64"
-	"errors"
-	"fmt"
-	"io"
-	"net/http"
-	"path/filepath"
-	"strings"
+	"encoding/hex"
 	"time"
 
-	"github.com/gin-gonic/gin"
-	"github.com/google/uuid"
 	log "github.com/sirupsen/logrus"
-	"github.com/spf13/viper"
+	"go.opentelemetry.io/otel/attribute"
 
+	"paste.org.cn/paste/server/cache"
+	"paste.org.cn/paste/server/db"
+	"paste.org.cn/paste/server/imaging"
+	"paste.org.cn/paste/server/metrics"
 	"paste.org.cn/paste/server/proto"
+	"paste.org.cn/paste/server/tracing"
 	"paste.org.cn/paste/server/util"
 )
 
@@ -53,27 +53,26 @@ type ImageStorageConfig struct {
 // 默认存储配置
 var StorageConfig ImageStorageConfig
 
-// InitializeStorage 初始化存储配置
-func InitializeStorage() {
-	// 从配置文件中读取存储配置
-	StorageConfig.Type = viper.GetString("storage.type")
+// InitializeStorage 初始化存储配置，c 用于缓存云存储的签名URL
+func InitializeStorage(c cache.Cache) {
+	// 从配置中读取存储配置
+	config := util.GetConfig().Storage
+	StorageConfig.Type = config.Type
 	if StorageConfig.Type == StorageTypeCloud {
 		// 读取具体的云服务提供商
-		provider := viper.GetString("storage.cloud.provider") // 从 storage.cloud.provider 读取
-		if provider == "" {
-			log.Errorf("未配置云存储提供商 (storage.cloud.provider)，回退到 base64 存储")
-			StorageConfig.Type = StorageTypeBase64
-			return
-		}
-
-		oss, err := NewOSSWithFactory(provider)
+		provider := config.Cloud.Provider
+		oss, err := NewOSSWithFactory(config.Cloud)
 		if err != nil {
 			log.Errorf("初始化云存储客户端失败 (%s): %+v", provider, err)
 			// 初始化失败时，回退到 base64 存储
 			StorageConfig.Type = StorageTypeBase64
 			return
 		}
-		StorageConfig.OSS = oss
+		StorageConfig.OSS = cachedOSS{OSS: oss, cache: c}
+		// 签名URL有效期支持热更新
+		util.RegisterReloadHook([]string{"storage.cloud.url_expire_at"}, func(cfg *util.Config) {
+			StorageConfig.OSS.SetURLExpire(time.Duration(cfg.Storage.Cloud.URLExpireAt) * time.Minute)
+		})
 		log.Infof("云存储 (%s) 初始化成功", provider)
 	} else {
 		StorageConfig.Type = StorageTypeBase64
@@ -81,134 +80,152 @@ func InitializeStorage() {
 	}
 }
 
-// 将文件转换为base64编码
-func FileToBase64(file io.Reader) (string, error) {
-	// 读取文件内容
-	fileBytes, err := io.ReadAll(file)
-	if err != nil {
-		return "", err
+// processImage 按当前配置处理图片
+func processImage(ctx context.Context, data []byte) (*imaging.Result, error) {
+	_, span := tracing.Start(ctx, "imaging.Process", attribute.Int("image.size", len(data)))
+	config := util.GetConfig().Image
+	result, err := imaging.Process(data, imaging.Options{
+		StripMetadata:   config.StripMetadata,
+		ThumbnailSize:   config.ThumbnailSize,
+		ReencodePNGSize: int64(config.ReencodePNGSize) << 10,
+	})
+	tracing.End(span, err)
+	return result, err
+}
+
+// storeBlob 按当前存储类型保存图片或附件，返回内容哈希，以及 Base64 编码的内容或对象存储中的 ObjectKey
+// 云存储按内容哈希去重，相同内容只上传一次，每次调用增加一次引用，ext 为对象名的扩展名
+func storeBlob(ctx context.Context, blobs db.Blob, contentType, ext string, data []byte) (hash, base64Content, objectKey string, err error) {
+	sum := sha256.Sum256(data)
+	hash = hex.EncodeToString(sum[:])
+
+	if StorageConfig.Type != StorageTypeCloud {
+		// Base64编码存储
+		return hash, base64.StdEncoding.EncodeToString(data), "", nil
 	}
 
-	// 转换为base64编码
-	return base64.StdEncoding.EncodeToString(fileBytes), nil
-}
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
 
-// 获取图片
-func UploadImages(c *gin.Context, log *log.Entry) (images []proto.ImageFile, err error) {
-	// 单独处理文件上传
-	form, err := c.MultipartForm()
+	blob, err := blobs.Acquire(ctx, db.BlobEntry{
+		Hash:        hash,
+		ObjectKey:   db.NewBlobObjectKey(hash, ext),
+		ContentType: contentType,
+		Size:        int64(len(data)),
+	})
 	if err != nil {
-		if err != http.ErrNotMultipart {
-			log.Errorf("解析 multipart form 失败: %+v", err)
-		}
-		return nil, nil
+		return "", "", "", err
 	}
-
-	files := form.File["images"]
-	if len(files) == 0 {
-		return nil, nil
+	if blob.Uploaded {
+		metrics.ImageDedupHits.Inc()
+		return hash, "", blob.ObjectKey, nil
 	}
 
-	// 检查图片数量限制
-	if len(files) > util.LimitConfig.ImagesCount() {
-		log.Errorf("图片数量过多: %d", len(files))
-		return nil, fmt.Errorf(proto.ErrTooManyCount, util.LimitConfig.ImagesCount())
+	// 对象尚未上传（新建或并发上传中），相同内容写入相同的 key，重复上传是安全的
+	err = StorageConfig.OSS.Upload(ctx, bytes.NewReader(data), UploadOptions{
+		ObjectKey:   blob.ObjectKey,
+		ContentType: contentType,
+	})
+	if err == nil {
+		err = blobs.MarkUploaded(ctx, hash)
 	}
+	if err != nil {
+		log.Errorf("上传文件到云存储失败: %+v", err)
+		blobs.Release(context.WithoutCancel(ctx), hash)
+		return "", "", "", err
+	}
+	return hash, "", blob.ObjectKey, nil
+}
 
-	// 处理每个图片
-	for _, fileHeader := range files {
-		// 检查文件大小
-		fileSizeMB := fileHeader.Size / (1024 * 1024)
-		if fileSizeMB > int64(util.LimitConfig.ImagesSize()) {
-			log.Errorf("图片太大: %d MB", fileSizeMB)
-			return nil, fmt.Errorf(proto.ErrOverMaxSize, util.LimitConfig.ImagesSize())
-		}
-
-		// 检查 MIME 类型
-		contentType := fileHeader.Header.Get("Content-Type")
-		if !strings.HasPrefix(contentType, "image/") {
-			log.Errorf("不支持的文件类型: %s", contentType)
-			return nil, errors.New(proto.ErrInvalidFileType)
-		}
-
-		// 生成唯一文件名
-		fileExt := filepath.Ext(fileHeader.Filename)
-		newFilename := fmt.Sprintf("%d_%s%s",
-			time.Now().UnixNano(),
-			uuid.NewString(), // 使用完整UUID以确保唯一性
-			fileExt)
-
-		// 创建图片对象
-		imageFile := proto.ImageFile{
-			StorageType: StorageConfig.Type,
-			Filename:    newFilename,
-			Size:        fileHeader.Size,
-			ContentType: contentType,
-		}
-
-		// 根据存储类型处理
-		file, err := fileHeader.Open()
-		if err != nil {
-			log.Errorf("打开文件 '%s' 失败: %+v", fileHeader.Filename, err)
-			return nil, fmt.Errorf("无法处理文件: %s", fileHeader.Filename)
-		}
-		defer file.Close()
-
-		switch StorageConfig.Type {
-		case StorageTypeBase64:
-			// Base64编码存储
-			imageFile.Base64Content, err = FileToBase64(file)
-			if err != nil {
-				log.Errorf("Base64编码失败: %+v", err)
-				return nil, err
-			}
-		case StorageTypeCloud:
-			// 上传图片到云存储
-			var err error
-			imageFile.ObjectKey, err = StorageInCloud(c, imageFile, file)
-			if err != nil {
-				log.Errorf("上传图片到云存储失败: %+v", err)
-				return nil, err
+// acquireUploadedBlob 按哈希登记已经上传到 objectKey 的内容并增加一次引用，返回分享中使用的 ObjectKey
+// 相同内容已上传时使用已有的对象并删除刚上传的对象；其他请求正在上传相同内容时，把内容复制到记录中的 key，
+// 保证记录的对象一定存在。登记前进程退出会遗留没有记录的对象，不影响分享
+func acquireUploadedBlob(ctx context.Context, blobs db.Blob, hash, objectKey, contentType string, size int64) (string, error) {
+	actx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	blob, err := blobs.Acquire(actx, db.BlobEntry{
+		Hash:        hash,
+		ObjectKey:   objectKey,
+		ContentType: contentType,
+		Size:        size,
+	})
+	cancel()
+	if err != nil {
+		deleteObject(context.WithoutCancel(ctx), objectKey)
+		return "", err
+	}
+	if blob.ObjectKey == objectKey {
+		// 新建的记录使用刚上传的对象
+		err = blobs.MarkUploaded(ctx, hash)
+	} else {
+		if blob.Uploaded {
+			metrics.ImageDedupHits.Inc()
+		} else {
+			err = copyObject(ctx, objectKey, blob.ObjectKey, contentType)
+			if err == nil {
+				err = blobs.MarkUploaded(ctx, hash)
 			}
 		}
-		images = append(images, imageFile)
+		deleteObject(context.WithoutCancel(ctx), objectKey)
 	}
-
-	return images, nil
+	if err != nil {
+		blobs.Release(context.WithoutCancel(ctx), hash)
+		return "", err
+	}
+	return blob.ObjectKey, nil
 }
 
-// StorageInCloud 将文件上传到云存储并返回 ObjectKey
-func StorageInCloud(c *gin.Context, imageFile proto.ImageFile, file io.Reader) (string, error) {
-	context, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
-	defer cancel()
+// copyObject 通过下载再上传把对象复制到新的 key
+func copyObject(ctx context.Context, from, to, contentType string) error {
+	body, err := StorageConfig.OSS.Download(ctx, from)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+	return StorageConfig.OSS.Upload(ctx, body, UploadOptions{ObjectKey: to, ContentType: contentType})
+}
 
-	// 获取过期时间
-	expiredAt := c.PostForm("expire_at")
-	var prefix string
-	switch expiredAt {
-	case ExpireAt1Hour:
-		prefix = "1hour/"
-	case ExpireAt1Day:
-		prefix = "1day/"
-	case ExpireAt1Month:
-		prefix = "1month/"
-	case ExpireAt1Year:
-		prefix = "1year/"
-	case ExpireAtPermanent:
-		prefix = "permanent/"
-	default:
-		prefix = "1hour/"
+// deleteObject 删除云存储对象，失败只记录日志
+func deleteObject(ctx context.Context, objectKey string) {
+	if err := StorageConfig.OSS.Delete(ctx, objectKey); err != nil {
+		log.Warnf("删除云存储对象 %s 失败: %+v", objectKey, err)
 	}
+}
 
-	opt := UploadOptions{
-		ObjectKey:   prefix + imageFile.Filename,
-		ContentType: imageFile.ContentType,
+// ReleaseAttachments 释放附件及缩略图的引用，用于上传后分享未能保存的情况
+func ReleaseAttachments(ctx context.Context, blobs db.Blob, attachments []proto.Attachment) {
+	var hashes []string
+	for _, image := range attachments {
+		if image.StorageType != StorageTypeCloud {
+			continue
+		}
+		hashes = append(hashes, image.Hash)
+		if image.Thumbnail != nil {
+			hashes = append(hashes, image.Thumbnail.Hash)
+		}
 	}
-	// 调用 Upload 方法，返回 ObjectKey
-	err := StorageConfig.OSS.Upload(context, file, opt)
-	if err != nil {
-		log.Errorf("上传图片到云存储失败: %+v", err)
-		return "", err
+	if len(hashes) == 0 {
+		return
+	}
+	if err := blobs.Release(ctx, hashes...); err != nil {
+		log.Warnf("释放附件引用失败: %+v", err)
+	}
+}
+
+// BlobCleanTask 返回删除没有引用的云存储对象的清理任务，未使用云存储时不做任何事
+func BlobCleanTask(blobs db.Blob) db.CleanTask {
+	return db.CleanTask{
+		Name: "无引用的云存储对象",
+		Clean: func(ctx context.Context) (int64, error) {
+			if StorageConfig.Type != StorageTypeCloud {
+				return 0, nil
+			}
+			return blobs.Collect(ctx, db.BlobGracePeriod, func(blob db.BlobEntry) error {
+				if err := StorageConfig.OSS.Delete(ctx, blob.ObjectKey); err != nil {
+					// 记录已删除，对象无法再被引用，只记录日志
+					log.Warnf("删除云存储对象 %s 失败: %+v", blob.ObjectKey, err)
+				}
+				return nil
+			})
+		},
 	}
-	return opt.ObjectKey, nil
 }
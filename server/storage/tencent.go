@@ -2,47 +2,30 @@ package storage
 
 import (
 	"context"
-	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
+	"sync/atomic"
 	"time"
 
-	"github.com/spf13/viper"
 	"github.com/tencentyun/cos-go-sdk-v5"
 	"github.com/tencentyun/cos-go-sdk-v5/debug"
+
+	"paste.org.cn/paste/server/util"
 )
 
 // TencentOSS 腾讯云对象存储
 type TencentOSS struct {
-	OSS    *cos.Client // 腾讯云对象存储客户端
-	Config OSSConfig   // 腾讯云配置
+	OSS       *cos.Client  // 腾讯云对象存储客户端
+	Config    OSSConfig    // 腾讯云配置
+	urlExpire atomic.Int64 // 签名URL有效期，支持热更新
 }
 
 // OSSConfig 腾讯云存储配置
-type OSSConfig struct {
-	Provider    string `mapstructure:"provider"`
-	Region      string `mapstructure:"region"`
-	Bucket      string `mapstructure:"bucket"`
-	SecretID    string `mapstructure:"secret_id"`
-	SecretKey   string `mapstructure:"secret_key"`
-	URLExpireAt int    `mapstructure:"url_expire_at"`
-}
-
-func NewTencentOSS() (*TencentOSS, error) {
-	newViper := viper.Sub("storage.cloud")
-	if newViper == nil {
-		return nil, errors.New("未找到 'storage.cloud' 配置")
-	}
-
-	// 解析腾讯云配置
-	var config OSSConfig
-	err := newViper.Unmarshal(&config)
-	if err != nil {
-		return nil, err
-	}
+type OSSConfig = util.CloudConfig
 
+func NewTencentOSS(config OSSConfig) (*TencentOSS, error) {
 	// 构建腾讯云COS的存储桶URL
 	// 格式: https://{bucket}.cos.{region}.myqcloud.com
 	url, err := url.Parse(fmt.Sprintf("https://%s.cos.%s.myqcloud.com", config.Bucket, config.Region))
@@ -69,10 +52,12 @@ func NewTencentOSS() (*TencentOSS, error) {
 			},
 		})
 
-	return &TencentOSS{
+	t := &TencentOSS{
 		OSS:    oss,
 		Config: config,
-	}, nil
+	}
+	t.SetURLExpire(time.Duration(config.URLExpireAt) * time.Minute)
+	return t, nil
 }
 
 func (t *TencentOSS) Upload(ctx context.Context, content io.Reader, opts UploadOptions) error {
@@ -99,6 +84,23 @@ func (t *TencentOSS) Upload(ctx context.Context, content io.Reader, opts UploadO
 	return nil
 }
 
+// Download 读取对象内容，调用方需要关闭返回的 Body
+func (t *TencentOSS) Download(ctx context.Context, objectKey string) (io.ReadCloser, error) {
+	resp, err := t.OSS.Object.Get(ctx, objectKey, nil)
+	if err != nil {
+		return nil, fmt.Errorf("腾讯云COS下载对象失败: %w", err)
+	}
+	return resp.Body, nil
+}
+
+// Delete 删除对象，对象不存在时不返回错误
+func (t *TencentOSS) Delete(ctx context.Context, objectKey string) error {
+	if _, err := t.OSS.Object.Delete(ctx, objectKey); err != nil {
+		return fmt.Errorf("腾讯云COS删除对象失败: %w", err)
+	}
+	return nil
+}
+
 func (t *TencentOSS) SetLifeCycle(ctx context.Context) error {
 	lc := &cos.BucketPutLifecycleOptions{
 		Rules: []cos.BucketLifecycleRule{
@@ -138,6 +140,15 @@ func (t *TencentOSS) SetLifeCycle(ctx context.Context) error {
 					Days: 372,
 				},
 			},
+			// 可续传上传的数据块由清理任务删除，这里作为兜底
+			{
+				ID:     "uploads_rule",
+				Filter: &cos.BucketLifecycleFilter{Prefix: uploadPrefix},
+				Status: "Enabled",
+				Expiration: &cos.BucketLifecycleExpiration{
+					Days: 7,
+				},
+			},
 		},
 	}
 	_, err := t.OSS.Bucket.PutLifecycle(ctx, lc)
@@ -156,8 +167,8 @@ func (t *TencentOSS) GetSignedURL(ctx context.Context, objectKey string) (string
 		objectKey,          // 对象键
 		t.Config.SecretID,  // 使用配置中的 SecretID
 		t.Config.SecretKey, // 使用配置中的 SecretKey
-		time.Duration(t.Config.URLExpireAt)*time.Minute, // URL 有效期
-		nil, // 不需要额外请求头
+		t.URLExpire(),      // URL 有效期
+		nil,                // 不需要额外请求头
 	)
 	if err != nil {
 		return "", fmt.Errorf("生成腾讯云COS预签名URL失败: %w", err)
@@ -165,3 +176,21 @@ func (t *TencentOSS) GetSignedURL(ctx context.Context, objectKey string) (string
 
 	return presignedURL.String(), nil
 }
+
+// URLExpire 返回当前的签名URL有效期
+func (t *TencentOSS) URLExpire() time.Duration {
+	return time.Duration(t.urlExpire.Load())
+}
+
+// SetURLExpire 更新签名URL有效期
+func (t *TencentOSS) SetURLExpire(d time.Duration) {
+	t.urlExpire.Store(int64(d))
+}
+
+// Ping 通过 HEAD Bucket 检查存储桶是否存在且有权限访问
+func (t *TencentOSS) Ping(ctx context.Context) error {
+	if _, err := t.OSS.Bucket.Head(ctx); err != nil {
+		return fmt.Errorf("腾讯云COS存储桶不可访问: %w", err)
+	}
+	return nil
+}
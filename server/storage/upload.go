@@ -0,0 +1,251 @@
+package storage
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/url"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/google/uuid"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/sync/errgroup"
+
+	"paste.org.cn/paste/server/db"
+	"paste.org.cn/paste/server/imaging"
+	"paste.org.cn/paste/server/metrics"
+	"paste.org.cn/paste/server/proto"
+	"paste.org.cn/paste/server/util"
+)
+
+// 上传图片和其他附件使用的表单字段名
+const (
+	imagesField      = "images"      // 只允许图片
+	attachmentsField = "attachments" // 允许图片和其他类型的附件，图片同样生成缩略图
+)
+
+// 表单普通字段的总大小上限，防止通过大量字段耗尽内存
+const maxFormValuesSize = 32 << 20
+
+// UploadAttachments 按顺序读取 multipart 请求的各个部分并保存其中的图片和附件，非 multipart 请求不做处理
+// 普通字段读取后写回请求，后续可以照常使用 ShouldBind 和 PostForm
+// 使用云存储时，图片以外的附件在读取请求体的同时直接上传，不在内存中缓存；
+// 图片需要完整解码，base64 存储需要保存完整内容，这些文件完整读入内存后交给工作协程处理和保存，
+// 工作协程已满时暂停读取请求体，同一请求占用的文件内存不超过 (image.concurrency + 1) 个文件的大小上限
+// 返回错误时已保存的附件引用会被释放，对象由清理任务删除
+func UploadAttachments(c *gin.Context, log *log.Entry, blobs db.Blob) (attachments []proto.Attachment, err error) {
+	if c.ContentType() != binding.MIMEMultipartPOSTForm {
+		return nil, nil
+	}
+	reader, err := c.Request.MultipartReader()
+	if err != nil {
+		log.Errorf("解析 multipart form 失败: %+v", err)
+		return nil, err
+	}
+
+	c.Set(util.STORAGETYPE, StorageConfig.Type)
+
+	ctx, cancel := context.WithCancel(c.Request.Context())
+	defer cancel()
+	g, gctx := errgroup.WithContext(ctx)
+	g.SetLimit(util.GetConfig().Image.Concurrency)
+
+	// 每个文件占一个位置，保证返回顺序与上传顺序一致
+	var (
+		slots  []*proto.Attachment
+		counts = map[string]int{}
+	)
+	values, err := readParts(reader, func(field, filename, contentType string, file *bufio.Reader) error {
+		// 附件字段中的文件在保存后才知道类型，这里只检查总数，保存完成后再按类型分别检查
+		limit := util.LimitConfig.ImagesCount()
+		if field == attachmentsField {
+			limit += util.LimitConfig.FilesCount()
+		}
+		if counts[field]++; counts[field] > limit {
+			log.Errorf("%s 字段的文件数量过多: %d", field, counts[field])
+			return fmt.Errorf(proto.ErrTooManyCount, limit)
+		}
+		slot := new(proto.Attachment)
+		slots = append(slots, slot)
+
+		// 图片字段只允许图片，附件字段按文件开头识别是否为图片
+		size := util.LimitConfig.ImagesSize()
+		if field == attachmentsField {
+			head, _ := file.Peek(sniffLength)
+			if _, err := imaging.Detect(head); err != nil {
+				if StorageConfig.Type == StorageTypeCloud {
+					// 不需要解码的附件在当前协程中边读边上传，上传期间暂停读取后续的部分
+					var err error
+					*slot, err = streamAttachment(gctx, log, blobs, filename, contentType, file)
+					return err
+				}
+				size = util.LimitConfig.FilesSize()
+			}
+		}
+		data, err := readFile(log, file, filename, size)
+		if err != nil {
+			return err
+		}
+
+		// 工作协程已满时阻塞，暂停读取请求体
+		g.Go(func() (err error) {
+			// 工作协程中的 panic 不会被 gin.Recovery 捕获，转换为错误，避免一个畸形文件导致进程退出
+			defer func() {
+				if r := recover(); r != nil {
+					log.Errorf("处理文件 '%s' 时发生 panic: %v\n%s", filename, r, debug.Stack())
+					err = errors.New(proto.ErrUploadFailed)
+				}
+			}()
+			if field == imagesField {
+				*slot, err = saveImage(gctx, log, blobs, filename, contentType, data)
+			} else {
+				*slot, err = saveAttachment(gctx, log, blobs, filename, contentType, data)
+			}
+			return err
+		})
+		return nil
+	})
+	if err != nil {
+		// 停止仍在处理的文件
+		cancel()
+	}
+	if werr := g.Wait(); err == nil {
+		err = werr
+	}
+
+	for _, slot := range slots {
+		// 只有保存成功的文件会设置存储类型
+		if slot.StorageType != "" {
+			attachments = append(attachments, *slot)
+		}
+	}
+	if err == nil {
+		err = checkAttachmentCounts(log, attachments)
+	}
+	if err != nil {
+		ReleaseAttachments(c, blobs, attachments)
+		return nil, err
+	}
+
+	// 把普通字段写回请求，ParseMultipartForm 发现 MultipartForm 已存在时直接返回
+	c.Request.MultipartForm = &multipart.Form{Value: values}
+	c.Request.PostForm = values
+	c.Request.Form = c.Request.URL.Query()
+	for k, v := range values {
+		c.Request.Form[k] = append(c.Request.Form[k], v...)
+	}
+	return attachments, nil
+}
+
+// readParts 依次读取 multipart 的各个部分，返回普通字段，图片和附件字段中的文件交给 onFile 读取和处理
+// onFile 返回后未读取的内容由 NextPart 丢弃
+func readParts(reader *multipart.Reader, onFile func(field, filename, contentType string, file *bufio.Reader) error) (url.Values, error) {
+	values := url.Values{}
+	remaining := int64(maxFormValuesSize)
+
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			return values, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		name, filename := part.FormName(), part.FileName()
+		switch {
+		case filename == "":
+			data, err := io.ReadAll(io.LimitReader(part, remaining+1))
+			if err != nil {
+				return nil, err
+			}
+			if remaining -= int64(len(data)); remaining < 0 {
+				return nil, errors.New("multipart: form values too large")
+			}
+			values.Add(name, string(data))
+		case name == imagesField, name == attachmentsField:
+			if err := onFile(name, filename, part.Header.Get("Content-Type"), bufio.NewReaderSize(part, sniffLength)); err != nil {
+				return nil, err
+			}
+		}
+		// 其他文件字段直接跳过，NextPart 会丢弃未读取的内容
+	}
+}
+
+// readFile 把文件完整读入内存，超过 size MB 时立即返回错误，最多只读取上限加一个字节
+func readFile(log *log.Entry, file io.Reader, filename string, size int) ([]byte, error) {
+	maxSize := int64(size) << 20
+	data, err := io.ReadAll(io.LimitReader(file, maxSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > maxSize {
+		log.Errorf("文件 '%s' 太大，超过 %d MB", filename, size)
+		return nil, fmt.Errorf(proto.ErrOverMaxSize, size)
+	}
+	return data, nil
+}
+
+// saveImage 校验并处理单张图片，按当前存储类型保存原图和缩略图
+func saveImage(ctx context.Context, log *log.Entry, blobs db.Blob, filename, contentType string, data []byte) (proto.Attachment, error) {
+	// 根据文件内容识别真实格式并完整解码，不信任客户端提供的 Content-Type 和扩展名
+	// 同时移除元数据、生成缩略图
+	result, err := processImage(ctx, data)
+	if err != nil {
+		log.Errorf("图片 '%s' (Content-Type: %s) 校验失败: %+v", filename, contentType, err)
+		return proto.Attachment{}, errors.New(proto.ErrInvalidFileType)
+	}
+	data, thumbnail := result.Original.Data, result.Thumbnail
+
+	// 创建图片对象，生成唯一文件名，扩展名使用识别出的格式
+	imageFile := proto.Attachment{
+		Kind: proto.AttachmentKindImage,
+		Name: attachmentName(filename),
+		Filename: fmt.Sprintf("%d_%s.%s",
+			time.Now().UnixNano(),
+			uuid.NewString(), // 使用完整UUID以确保唯一性
+			result.Format),
+		Size:        int64(len(data)),
+		ContentType: result.Original.ContentType,
+		Width:       result.Original.Width,
+		Height:      result.Original.Height,
+	}
+
+	start := time.Now()
+	imageFile.Hash, imageFile.Base64Content, imageFile.ObjectKey, err = storeBlob(ctx, blobs, imageFile.ContentType, "."+result.Format, data)
+	if err != nil {
+		log.Errorf("保存图片失败: %+v", err)
+		return proto.Attachment{}, err
+	}
+	if thumbnail != nil {
+		variant := proto.ImageVariant{
+			Size:        int64(len(thumbnail.Data)),
+			ContentType: thumbnail.ContentType,
+			Width:       thumbnail.Width,
+			Height:      thumbnail.Height,
+		}
+		variant.Hash, variant.Base64Content, variant.ObjectKey, err = storeBlob(ctx, blobs, variant.ContentType, "."+strings.TrimPrefix(variant.ContentType, "image/"), thumbnail.Data)
+		if err != nil {
+			log.Errorf("保存缩略图失败: %+v", err)
+			// 原图已经保存，需要释放引用
+			imageFile.StorageType = StorageConfig.Type
+			ReleaseAttachments(context.WithoutCancel(ctx), blobs, []proto.Attachment{imageFile})
+			return proto.Attachment{}, err
+		}
+		imageFile.Thumbnail = &variant
+		metrics.ImageBytes.WithLabelValues(StorageConfig.Type).Add(float64(variant.Size))
+	}
+	metrics.ImageBytes.WithLabelValues(StorageConfig.Type).Add(float64(imageFile.Size))
+	metrics.ImageUploadDuration.WithLabelValues(StorageConfig.Type).Observe(time.Since(start).Seconds())
+
+	// 保存成功后才设置存储类型，UploadAttachments 据此判断哪些图片需要在失败时释放
+	imageFile.StorageType = StorageConfig.Type
+	return imageFile, nil
+}
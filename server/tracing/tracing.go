@@ -0,0 +1,147 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// 导出器类型
+const (
+	ExporterNone   = "none"   // 不导出，只生成 trace ID 用于日志关联
+	ExporterStdout = "stdout" // 输出到标准输出，便于本地调试
+	ExporterOTLP   = "otlp"   // 通过 OTLP/HTTP 导出到 collector
+)
+
+// 定义常量 SPAN，用于在 gin 上下文中保存当前请求的 span
+// gin.Context 只能通过字符串键查找值，handler 中派生出的 context 通过该键找到父 span
+const SPAN string = "OtelSpan"
+
+// 全局 tracer 名称
+const tracerName = "paste.org.cn/paste/server"
+
+// Config 链路追踪配置
+type Config struct {
+	Exporter    string  `mapstructure:"exporter" json:"exporter"`         // 导出器: none, stdout, otlp
+	Endpoint    string  `mapstructure:"endpoint" json:"endpoint"`         // OTLP collector 地址，例如 localhost:4318
+	Insecure    bool    `mapstructure:"insecure" json:"insecure"`         // OTLP 是否使用 HTTP 而不是 HTTPS
+	SampleRatio float64 `mapstructure:"sample_ratio" json:"sample_ratio"` // 采样率 0~1
+	ServiceName string  `mapstructure:"service_name" json:"service_name"` // 上报的服务名
+}
+
+// Validate 校验链路追踪配置，返回所有问题
+func (c Config) Validate() []string {
+	var errs []string
+	switch c.Exporter {
+	case ExporterNone, ExporterStdout:
+	case ExporterOTLP:
+		if c.Endpoint == "" {
+			errs = append(errs, "trace.endpoint: must not be empty when trace.exporter is otlp")
+		}
+	default:
+		errs = append(errs, fmt.Sprintf("trace.exporter: must be one of none, stdout, otlp, got %q", c.Exporter))
+	}
+	if c.SampleRatio < 0 || c.SampleRatio > 1 {
+		errs = append(errs, fmt.Sprintf("trace.sample_ratio: must be between 0 and 1, got %v", c.SampleRatio))
+	}
+	return errs
+}
+
+// Init 初始化全局 TracerProvider 和 W3C traceparent 传播器，返回用于关闭的函数
+func Init(ctx context.Context, config Config) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	opts := []sdktrace.TracerProviderOption{
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(config.SampleRatio))),
+	}
+
+	var exporter sdktrace.SpanExporter
+	var err error
+	switch config.Exporter {
+	case ExporterStdout:
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+	case ExporterOTLP:
+		clientOpts := []otlptracehttp.Option{otlptracehttp.WithEndpoint(config.Endpoint)}
+		if config.Insecure {
+			clientOpts = append(clientOpts, otlptracehttp.WithInsecure())
+		}
+		exporter, err = otlptracehttp.New(ctx, clientOpts...)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if exporter != nil {
+		opts = append(opts, sdktrace.WithBatcher(exporter))
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(
+		semconv.SchemaURL,
+		semconv.ServiceName(config.ServiceName),
+	))
+	if err != nil {
+		return nil, err
+	}
+	opts = append(opts, sdktrace.WithResource(res))
+
+	provider := sdktrace.NewTracerProvider(opts...)
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
+
+// Start 创建一个子 span
+// 如果 ctx 中没有 span，会通过 SPAN 键查找 gin 上下文中保存的请求 span 作为父 span
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	if !trace.SpanContextFromContext(ctx).IsValid() {
+		if parent, ok := ctx.Value(SPAN).(trace.Span); ok {
+			ctx = trace.ContextWithSpan(ctx, parent)
+		}
+	}
+	return otel.Tracer(tracerName).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// StartServer 从请求头中解析 W3C traceparent，并创建服务端 span
+func StartServer(ctx context.Context, header http.Header, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	ctx = otel.GetTextMapPropagator().Extract(ctx, propagation.HeaderCarrier(header))
+	return otel.Tracer(tracerName).Start(ctx, name,
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(attrs...),
+	)
+}
+
+// End 结束 span，err 不为空时记录错误
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// TraceID 返回 ctx 中当前 span 的 trace ID，没有时返回空字符串
+func TraceID(ctx context.Context) string {
+	spanCtx := trace.SpanContextFromContext(ctx)
+	if !spanCtx.IsValid() {
+		if span, ok := ctx.Value(SPAN).(trace.Span); ok {
+			spanCtx = span.SpanContext()
+		}
+	}
+	if !spanCtx.HasTraceID() {
+		return ""
+	}
+	return spanCtx.TraceID().String()
+}
@@ -0,0 +1,72 @@
+package util
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"slices"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// 角色常量
+const (
+	RoleAdmin = "admin" // 管理员，可访问 /admin/v1 管理接口
+	RoleUser  = "user"  // 普通认证用户
+)
+
+// 定义常量 USER，用于在 gin 上下文中保存当前认证用户
+const USER string = "User"
+
+// Credential 表示一个可用于认证的访问令牌
+type Credential struct {
+	Name  string `mapstructure:"name" json:"name"`   // 令牌持有者名称，用于日志与审计
+	Token string `mapstructure:"token" json:"token"` // 访问令牌
+	Role  string `mapstructure:"role" json:"role"`   // 角色：admin, user
+}
+
+// String 输出令牌名称和角色，不包含令牌本身
+func (c Credential) String() string {
+	return fmt.Sprintf("%s(%s)", c.Name, c.Role)
+}
+
+type authConfig struct {
+	mu          sync.RWMutex // 保护配置读写的互斥锁
+	Credentials []Credential
+}
+
+var AuthConfig authConfig
+
+// InitializeAuth 从当前配置加载访问令牌
+func InitializeAuth() {
+	tokens := GetConfig().Auth.Tokens
+	if len(tokens) == 0 {
+		log.Warn("未配置访问令牌 (auth.tokens)，管理接口将不可用")
+	}
+	AuthConfig.apply(tokens)
+
+	log.Infof("Auth configuration initialized successfully, %d token(s) loaded", len(tokens))
+}
+
+// apply 替换当前生效的访问令牌
+func (ac *authConfig) apply(creds []Credential) {
+	ac.mu.Lock()
+	defer ac.mu.Unlock()
+	ac.Credentials = slices.Clone(creds)
+}
+
+// Authenticate 根据令牌查找对应的认证信息，使用常量时间比较防止时序攻击
+func (ac *authConfig) Authenticate(token string) (Credential, bool) {
+	ac.mu.RLock()
+	defer ac.mu.RUnlock()
+
+	if token == "" {
+		return Credential{}, false
+	}
+	for _, cred := range ac.Credentials {
+		if subtle.ConstantTimeCompare([]byte(cred.Token), []byte(token)) == 1 {
+			return cred, true
+		}
+	}
+	return Credential{}, false
+}
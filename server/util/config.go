@@ -0,0 +1,564 @@
+package util
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync/atomic"
+
+	"github.com/mitchellh/mapstructure"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+
+	"paste.org.cn/paste/server/tracing"
+)
+
+// EnvPrefix 环境变量前缀，配置项 storage.cloud.secret_key 对应环境变量 PASTE_STORAGE_CLOUD_SECRET_KEY
+const EnvPrefix = "PASTE"
+
+// 默认配置文件名，未通过 --config 指定时在当前目录查找
+const defaultConfigName = "config"
+
+// 密钥类配置在输出时使用的掩码
+const secretMask = "******"
+
+// CSP frame-ancestors 允许的来源：*、'self'，或带可选协议、通配子域名和端口的主机，例如 https://*.example.com:8443
+var frameAncestorPattern = regexp.MustCompile(`^(\*|'self'|([a-z][a-z0-9+.-]*://)?(\*\.)?[A-Za-z0-9-]+(\.[A-Za-z0-9-]+)*(:(\d+|\*))?)$`)
+
+// Config 服务的完整配置
+type Config struct {
+	Log       LogConfig       `mapstructure:"log" json:"log"`
+	Server    ServerConfig    `mapstructure:"server" json:"server"`
+	Paste     PasteConfig     `mapstructure:"paste" json:"paste"`
+	Storage   StorageConfig   `mapstructure:"storage" json:"storage"`
+	Limit     Limits          `mapstructure:"limit" json:"limit"`
+	Cleaner   CleanerConfig   `mapstructure:"cleaner" json:"cleaner"`
+	Cache     CacheConfig     `mapstructure:"cache" json:"cache"`
+	Image     ImageConfig     `mapstructure:"image" json:"image"`
+	Upload    UploadConfig    `mapstructure:"upload" json:"upload"`
+	Embed     EmbedConfig     `mapstructure:"embed" json:"embed"`
+	RateLimit RateLimitConfig `mapstructure:"rate_limit" json:"rate_limit"`
+	Auth      AuthSettings    `mapstructure:"auth" json:"auth"`
+	Trace     tracing.Config  `mapstructure:"trace" json:"trace"`
+}
+
+// EmbedConfig 在其他网站中嵌入分享的配置
+type EmbedConfig struct {
+	Enabled        bool     `mapstructure:"enabled" json:"enabled"`                 // 是否允许通过 embed.js 和 iframe 嵌入分享
+	FrameAncestors []string `mapstructure:"frame_ancestors" json:"frame_ancestors"` // 允许嵌入的页面来源，写入 CSP frame-ancestors
+}
+
+// RateLimitConfig 按客户端 IP 限制请求速率的配置，使用令牌桶算法
+type RateLimitConfig struct {
+	Enabled bool    `mapstructure:"enabled" json:"enabled"` // 是否限制请求速率
+	Rate    float64 `mapstructure:"rate" json:"rate"`       // 每个 IP 每秒补充的请求数
+	Burst   int     `mapstructure:"burst" json:"burst"`     // 每个 IP 允许的突发请求数
+}
+
+// LogConfig 日志配置
+type LogConfig struct {
+	Level      string `mapstructure:"level" json:"level"`             // 日志级别
+	Format     string `mapstructure:"format" json:"format"`           // 日志格式: text, json
+	File       string `mapstructure:"file" json:"file"`               // 日志文件路径，为空时输出到标准输出
+	MaxSize    int    `mapstructure:"max_size" json:"max_size"`       // 单个日志文件最大大小 MB
+	MaxBackups int    `mapstructure:"max_backups" json:"max_backups"` // 保留的历史日志文件数量，0 表示不限制
+	MaxAge     int    `mapstructure:"max_age" json:"max_age"`         // 历史日志文件保留天数，0 表示不限制
+	Compress   bool   `mapstructure:"compress" json:"compress"`       // 是否压缩历史日志文件
+}
+
+// ServerConfig HTTP 服务配置
+type ServerConfig struct {
+	Host       string `mapstructure:"host" json:"host"`               // 监听地址
+	DrainDelay int    `mapstructure:"drain_delay" json:"drain_delay"` // 关闭前 /readyz 返回失败并等待负载均衡摘除流量的时间（秒）
+	PublicURL  string `mapstructure:"public_url" json:"public_url"`   // 生成分享链接使用的公开地址，为空时根据请求的 Host 生成
+}
+
+// PasteConfig 分享内容存储配置
+type PasteConfig struct {
+	Mgo     MongoConfig   `mapstructure:"mgo" json:"mgo"`
+	Snippet SnippetConfig `mapstructure:"snippet" json:"snippet"`
+	Key     KeyConfig     `mapstructure:"key" json:"key"`
+}
+
+// KeyConfig 分享 key 的生成配置
+type KeyConfig struct {
+	Mode       string   `mapstructure:"mode" json:"mode"`               // 生成方式: random, words
+	Length     int      `mapstructure:"length" json:"length"`           // 随机 key 的长度
+	Alphabet   string   `mapstructure:"alphabet" json:"alphabet"`       // 随机 key 使用的字符集
+	MaxRetries int      `mapstructure:"max_retries" json:"max_retries"` // key 重复时最多尝试的次数
+	Reserved   []string `mapstructure:"reserved" json:"reserved"`       // 内置保留字以外，不允许作为 key 的单词
+}
+
+// SnippetConfig 代码片段的存储配置
+type SnippetConfig struct {
+	Compression     string `mapstructure:"compression" json:"compression"`             // 压缩算法: zstd, gzip, none
+	CompressMinSize int    `mapstructure:"compress_min_size" json:"compress_min_size"` // 超过该大小的片段才压缩 KB
+	SpillSize       int    `mapstructure:"spill_size" json:"spill_size"`               // 压缩后超过该大小的片段转存到 GridFS KB
+}
+
+// MongoConfig 存储 MongoDB 的连接配置
+type MongoConfig struct {
+	Host       string `mapstructure:"host" json:"host"`               // 连接地址
+	DB         string `mapstructure:"db" json:"db"`                   // 数据库名
+	Coll       string `mapstructure:"coll" json:"coll"`               // 集合名
+	AuditColl  string `mapstructure:"audit_coll" json:"audit_coll"`   // 审计事件集合名
+	BlobColl   string `mapstructure:"blob_coll" json:"blob_coll"`     // 去重图片对象集合名
+	UploadColl string `mapstructure:"upload_coll" json:"upload_coll"` // 可续传上传集合名
+}
+
+// StorageConfig 图片存储配置
+type StorageConfig struct {
+	Type  string      `mapstructure:"type" json:"type"` // 存储类型: base64, cloud
+	Cloud CloudConfig `mapstructure:"cloud" json:"cloud"`
+}
+
+// CloudConfig 云存储配置
+type CloudConfig struct {
+	Provider    string `mapstructure:"provider" json:"provider"`           // 云存储提供商
+	Region      string `mapstructure:"region" json:"region"`               // 云存储区域
+	Bucket      string `mapstructure:"bucket" json:"bucket"`               // 云存储桶
+	SecretID    string `mapstructure:"secret_id" json:"secret_id"`         // 云存储访问密钥
+	SecretKey   string `mapstructure:"secret_key" json:"secret_key"`       // 云存储密钥
+	URLExpireAt int    `mapstructure:"url_expire_at" json:"url_expire_at"` // 图片签名URL有效期 分钟
+}
+
+// CleanerConfig 清理任务配置
+type CleanerConfig struct {
+	Interval int `mapstructure:"interval" json:"interval"` // 清理间隔 分钟
+}
+
+// CacheConfig 读缓存配置
+type CacheConfig struct {
+	Type       string `mapstructure:"type" json:"type"`               // 缓存类型: memory, none
+	MaxEntries int    `mapstructure:"max_entries" json:"max_entries"` // 最大缓存条目数
+	MaxSize    int    `mapstructure:"max_size" json:"max_size"`       // 最大缓存大小 MB
+	TTL        int    `mapstructure:"ttl" json:"ttl"`                 // 分享内容的缓存时间 秒
+}
+
+// ImageConfig 图片处理配置
+type ImageConfig struct {
+	StripMetadata   bool `mapstructure:"strip_metadata" json:"strip_metadata"`       // 是否移除 EXIF 等元数据
+	ThumbnailSize   int  `mapstructure:"thumbnail_size" json:"thumbnail_size"`       // 缩略图最长边的像素数，0 表示不生成
+	ReencodePNGSize int  `mapstructure:"reencode_png_size" json:"reencode_png_size"` // 超过该大小的 PNG 重新压缩 KB，0 表示不处理
+	Concurrency     int  `mapstructure:"concurrency" json:"concurrency"`             // 单个请求同时处理和上传的图片数
+}
+
+// UploadConfig 可续传上传配置
+type UploadConfig struct {
+	Expire int `mapstructure:"expire" json:"expire"` // 上传的有效期 小时，过期后未完成或未被引用的上传会被清理
+}
+
+// AuthSettings 访问令牌配置
+type AuthSettings struct {
+	Tokens []Credential `mapstructure:"tokens" json:"tokens"`
+}
+
+// defaultConfig 返回默认配置，配置文件和环境变量中未设置的项使用这里的值
+func defaultConfig() Config {
+	return Config{
+		Log: LogConfig{
+			Level:      "info",
+			Format:     LogFormatText,
+			MaxSize:    100,
+			MaxBackups: 7,
+			MaxAge:     30,
+		},
+		Server: ServerConfig{Host: "0.0.0.0:8000", DrainDelay: 5},
+		Paste: PasteConfig{
+			Mgo: MongoConfig{
+				Host:       "mongodb://mongo:27017",
+				DB:         "paste",
+				Coll:       "paste",
+				AuditColl:  "audit",
+				BlobColl:   "blobs",
+				UploadColl: "uploads",
+			},
+			Snippet: SnippetConfig{
+				Compression:     "zstd",
+				CompressMinSize: 1,
+				SpillSize:       1024,
+			},
+			Key: KeyConfig{
+				Mode:       KeyModeRandom,
+				Length:     10,
+				Alphabet:   DefaultKeyAlphabet,
+				MaxRetries: 5,
+			},
+		},
+		Storage: StorageConfig{
+			Type:  "base64",
+			Cloud: CloudConfig{URLExpireAt: 15},
+		},
+		Limit: Limits{
+			SnippetsLength: 5000000,
+			SnippetsCount:  10,
+			ImagesSize:     10,
+			ImagesCount:    3,
+			FilesSize:      5,
+			FilesCount:     3,
+		},
+		Cleaner: CleanerConfig{Interval: 60},
+		Cache: CacheConfig{
+			Type:       "memory",
+			MaxEntries: 10000,
+			MaxSize:    64,
+			TTL:        300,
+		},
+		Image: ImageConfig{
+			StripMetadata:   true,
+			ThumbnailSize:   320,
+			ReencodePNGSize: 1024,
+			Concurrency:     2,
+		},
+		Upload: UploadConfig{Expire: 24},
+		Embed: EmbedConfig{
+			Enabled:        true,
+			FrameAncestors: []string{"*"},
+		},
+		RateLimit: RateLimitConfig{
+			Enabled: false,
+			Rate:    10,
+			Burst:   30,
+		},
+		Trace: tracing.Config{
+			Exporter:    tracing.ExporterNone,
+			Endpoint:    "localhost:4318",
+			Insecure:    true,
+			SampleRatio: 1,
+			ServiceName: "paste",
+		},
+	}
+}
+
+// ConfigErrors 汇总了配置校验发现的所有问题
+type ConfigErrors []string
+
+func (e ConfigErrors) Error() string {
+	return "invalid config:\n  - " + strings.Join(e, "\n  - ")
+}
+
+// Validate 校验配置，一次性返回所有问题
+func (c *Config) Validate() error {
+	var errs ConfigErrors
+	add := func(format string, args ...any) {
+		errs = append(errs, fmt.Sprintf(format, args...))
+	}
+
+	if _, err := log.ParseLevel(c.Log.Level); err != nil {
+		add("log.level: %v", err)
+	}
+	if c.Log.Format != LogFormatText && c.Log.Format != LogFormatJSON {
+		add("log.format: must be one of text, json, got %q", c.Log.Format)
+	}
+	if c.Log.File != "" && c.Log.MaxSize <= 0 {
+		add("log.max_size: must be positive when log.file is set, got %d", c.Log.MaxSize)
+	}
+	if c.Log.MaxBackups < 0 || c.Log.MaxAge < 0 {
+		add("log.max_backups/max_age: must not be negative")
+	}
+
+	if _, _, err := net.SplitHostPort(c.Server.Host); err != nil {
+		add("server.host: %v", err)
+	}
+	if c.Server.DrainDelay < 0 {
+		add("server.drain_delay: must not be negative, got %d", c.Server.DrainDelay)
+	}
+	if c.Server.PublicURL != "" {
+		if u, err := url.Parse(c.Server.PublicURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || u.RawQuery != "" || u.Fragment != "" {
+			add("server.public_url: must be an absolute http:// or https:// URL without query, got %q", c.Server.PublicURL)
+		}
+	}
+
+	if u, err := url.Parse(c.Paste.Mgo.Host); err != nil || (u.Scheme != "mongodb" && u.Scheme != "mongodb+srv") {
+		add("paste.mgo.host: must be a mongodb:// or mongodb+srv:// URI")
+	}
+	if c.Paste.Mgo.DB == "" {
+		add("paste.mgo.db: must not be empty")
+	}
+	if c.Paste.Mgo.Coll == "" {
+		add("paste.mgo.coll: must not be empty")
+	}
+	if c.Paste.Mgo.AuditColl == "" || c.Paste.Mgo.AuditColl == c.Paste.Mgo.Coll {
+		add("paste.mgo.audit_coll: must not be empty and must differ from paste.mgo.coll")
+	}
+	if mgo := c.Paste.Mgo; mgo.BlobColl == "" || mgo.BlobColl == mgo.Coll || mgo.BlobColl == mgo.AuditColl {
+		add("paste.mgo.blob_coll: must not be empty and must differ from paste.mgo.coll and paste.mgo.audit_coll")
+	}
+	if mgo := c.Paste.Mgo; mgo.UploadColl == "" || mgo.UploadColl == mgo.Coll || mgo.UploadColl == mgo.AuditColl || mgo.UploadColl == mgo.BlobColl {
+		add("paste.mgo.upload_coll: must not be empty and must differ from the other paste.mgo collections")
+	}
+
+	switch c.Paste.Snippet.Compression {
+	case "zstd", "gzip", "none":
+	default:
+		add("paste.snippet.compression: must be one of zstd, gzip, none, got %q", c.Paste.Snippet.Compression)
+	}
+	if c.Paste.Snippet.CompressMinSize < 0 {
+		add("paste.snippet.compress_min_size: must not be negative, got %d", c.Paste.Snippet.CompressMinSize)
+	}
+	if c.Paste.Snippet.SpillSize <= 0 {
+		add("paste.snippet.spill_size: must be positive, got %d", c.Paste.Snippet.SpillSize)
+	}
+
+	if mode := c.Paste.Key.Mode; mode != KeyModeRandom && mode != KeyModeWords {
+		add("paste.key.mode: must be one of random, words, got %q", mode)
+	}
+	if key := c.Paste.Key; key.Length < MinKeyLength || key.Length > MaxKeyLength {
+		add("paste.key.length: must be between %d and %d, got %d", MinKeyLength, MaxKeyLength, key.Length)
+	}
+	if err := validateKeyAlphabet(c.Paste.Key.Alphabet); err != nil {
+		add("paste.key.alphabet: %v", err)
+	}
+	if c.Paste.Key.MaxRetries <= 0 {
+		add("paste.key.max_retries: must be positive, got %d", c.Paste.Key.MaxRetries)
+	}
+	for _, word := range c.Paste.Key.Reserved {
+		if !IsValidKey(word) {
+			add("paste.key.reserved: %q is not a valid key", word)
+		}
+	}
+
+	switch c.Storage.Type {
+	case "base64":
+	case "cloud":
+		cloud := c.Storage.Cloud
+		if cloud.Provider == "" {
+			add("storage.cloud.provider: must not be empty when storage.type is cloud")
+		}
+		if cloud.Region == "" {
+			add("storage.cloud.region: must not be empty when storage.type is cloud")
+		}
+		if cloud.Bucket == "" {
+			add("storage.cloud.bucket: must not be empty when storage.type is cloud")
+		}
+		if cloud.SecretID == "" || cloud.SecretKey == "" {
+			add("storage.cloud.secret_id/secret_key: must not be empty when storage.type is cloud")
+		}
+		if cloud.URLExpireAt <= 0 {
+			add("storage.cloud.url_expire_at: must be positive, got %d", cloud.URLExpireAt)
+		}
+	default:
+		add("storage.type: must be one of base64, cloud, got %q", c.Storage.Type)
+	}
+
+	if err := c.Limit.Validate(); err != nil {
+		add("limit: %v", err)
+	}
+
+	if c.Cleaner.Interval <= 0 {
+		add("cleaner.interval: must be positive, got %d", c.Cleaner.Interval)
+	}
+
+	switch c.Cache.Type {
+	case "none":
+	case "memory":
+		if c.Cache.MaxEntries <= 0 || c.Cache.MaxSize <= 0 {
+			add("cache.max_entries/max_size: must be positive when cache.type is memory")
+		}
+		if c.Cache.TTL <= 0 {
+			add("cache.ttl: must be positive, got %d", c.Cache.TTL)
+		}
+	default:
+		add("cache.type: must be one of memory, none, got %q", c.Cache.Type)
+	}
+
+	if c.Image.ThumbnailSize < 0 || c.Image.ReencodePNGSize < 0 {
+		add("image.thumbnail_size/reencode_png_size: must not be negative")
+	}
+	if c.Image.Concurrency <= 0 {
+		add("image.concurrency: must be positive, got %d", c.Image.Concurrency)
+	}
+	// 云存储中的数据块由生命周期规则在 7 天后兜底删除，有效期不能超过该时间
+	if c.Upload.Expire <= 0 || c.Upload.Expire > 72 {
+		add("upload.expire: must be between 1 and 72 hours, got %d", c.Upload.Expire)
+	}
+	if c.Embed.Enabled && len(c.Embed.FrameAncestors) == 0 {
+		add("embed.frame_ancestors: must not be empty when embed.enabled is true")
+	}
+	for _, source := range c.Embed.FrameAncestors {
+		if !frameAncestorPattern.MatchString(source) {
+			add("embed.frame_ancestors: invalid source %q, expected *, 'self' or a scheme/host such as https://wiki.example.com", source)
+		}
+	}
+
+	if c.RateLimit.Enabled && (c.RateLimit.Rate <= 0 || c.RateLimit.Burst <= 0) {
+		add("rate_limit.rate/burst: must be positive when rate_limit.enabled is true")
+	}
+
+	names := make(map[string]bool)
+	for i, cred := range c.Auth.Tokens {
+		if cred.Token == "" {
+			add("auth.tokens[%d]: token must not be empty", i)
+		}
+		if cred.Role != RoleAdmin && cred.Role != RoleUser {
+			add("auth.tokens[%d]: role must be one of admin, user, got %q", i, cred.Role)
+		}
+		if names[cred.Name] {
+			add("auth.tokens[%d]: duplicate name %q", i, cred.Name)
+		}
+		names[cred.Name] = true
+	}
+
+	errs = append(errs, c.Trace.Validate()...)
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// Masked 返回隐藏了密钥的配置副本，用于输出和日志
+func (c *Config) Masked() *Config {
+	masked := *c
+	masked.Paste.Mgo.Host = maskURI(c.Paste.Mgo.Host)
+	if masked.Storage.Cloud.SecretID != "" {
+		masked.Storage.Cloud.SecretID = secretMask
+	}
+	if masked.Storage.Cloud.SecretKey != "" {
+		masked.Storage.Cloud.SecretKey = secretMask
+	}
+	masked.Auth.Tokens = make([]Credential, len(c.Auth.Tokens))
+	for i, cred := range c.Auth.Tokens {
+		if cred.Token != "" {
+			cred.Token = secretMask
+		}
+		masked.Auth.Tokens[i] = cred
+	}
+	return &masked
+}
+
+// maskURI 隐藏连接地址中的密码
+func maskURI(uri string) string {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return uri
+	}
+	return u.Redacted()
+}
+
+// 当前生效的配置
+var (
+	currentConfig atomic.Pointer[Config]
+	configFile    string // 当前使用的配置文件路径，为空表示未使用配置文件
+)
+
+// GetConfig 返回当前生效的配置
+func GetConfig() *Config {
+	if cfg := currentConfig.Load(); cfg != nil {
+		return cfg
+	}
+	cfg := defaultConfig()
+	return &cfg
+}
+
+// ReadConfig 读取配置，优先级为 环境变量 > 配置文件 > 默认值
+// file 为空时在当前目录查找 config.yaml，找不到时只使用环境变量和默认值
+func ReadConfig(file string) (*Config, string, error) {
+	v := viper.New()
+	if file != "" {
+		if _, err := os.Stat(file); err != nil {
+			return nil, "", fmt.Errorf("read config file: %w", err)
+		}
+		v.SetConfigFile(file)
+	} else {
+		v.SetConfigName(defaultConfigName)
+		v.AddConfigPath(".")
+	}
+
+	// 为每一个配置项设置默认值并绑定 PASTE_ 前缀的环境变量
+	defaults := defaultConfig()
+	for key, value := range flattenConfig(reflect.ValueOf(defaults), "") {
+		v.SetDefault(key, value)
+		_ = v.BindEnv(key, EnvPrefix+"_"+strings.ToUpper(strings.ReplaceAll(key, ".", "_")))
+	}
+
+	if err := v.ReadInConfig(); err != nil {
+		var notFound viper.ConfigFileNotFoundError
+		if file != "" || !errors.As(err, &notFound) {
+			return nil, "", fmt.Errorf("read config file: %w", err)
+		}
+		log.Warn("未找到配置文件，仅使用环境变量和默认配置")
+	}
+
+	var cfg Config
+	err := v.Unmarshal(&cfg, viper.DecodeHook(mapstructure.ComposeDecodeHookFunc(
+		jsonStringToSliceHook,
+		mapstructure.StringToTimeDurationHookFunc(),
+		mapstructure.StringToSliceHookFunc(","),
+	)))
+	if err != nil {
+		return nil, "", fmt.Errorf("decode config: %w", err)
+	}
+
+	// 未设置角色的令牌默认为普通用户
+	for i := range cfg.Auth.Tokens {
+		if cfg.Auth.Tokens[i].Role == "" {
+			cfg.Auth.Tokens[i].Role = RoleUser
+		}
+	}
+	return &cfg, v.ConfigFileUsed(), nil
+}
+
+// LoadConfig 读取并校验配置，校验通过后作为当前生效的配置
+func LoadConfig(file string) (*Config, error) {
+	cfg, used, err := ReadConfig(file)
+	if err != nil {
+		return nil, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	configFile = used
+	currentConfig.Store(cfg)
+
+	InitializeLogger(cfg.Log)
+	return cfg, nil
+}
+
+// flattenConfig 将配置结构体展开为 "a.b.c" 形式的键值对
+func flattenConfig(v reflect.Value, prefix string) map[string]any {
+	result := make(map[string]any)
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("mapstructure")
+		if tag == "" || !field.IsExported() {
+			continue
+		}
+		key := tag
+		if prefix != "" {
+			key = prefix + "." + tag
+		}
+		if field.Type.Kind() == reflect.Struct {
+			for k, value := range flattenConfig(v.Field(i), key) {
+				result[k] = value
+			}
+			continue
+		}
+		result[key] = v.Field(i).Interface()
+	}
+	return result
+}
+
+// jsonStringToSliceHook 允许通过 JSON 字符串设置列表类配置，例如 PASTE_AUTH_TOKENS='[{"name":"ops","token":"xxx","role":"admin"}]'
+func jsonStringToSliceHook(from, to reflect.Type, data any) (any, error) {
+	s, ok := data.(string)
+	if !ok || from.Kind() != reflect.String || to.Kind() != reflect.Slice {
+		return data, nil
+	}
+	if s = strings.TrimSpace(s); !strings.HasPrefix(s, "[") {
+		return data, nil
+	}
+	var list []any
+	if err := json.Unmarshal([]byte(s), &list); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
@@ -0,0 +1,79 @@
+package util
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// key 的生成方式
+const (
+	KeyModeRandom = "random" // 从字符集中随机选取 paste.key.length 个字符
+	KeyModeWords  = "words"  // 形容词-形容词-动物-四位数字，例如 brave-quiet-otter-4821
+)
+
+// 默认的随机 key 字符集
+const DefaultKeyAlphabet = "0123456789abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"
+
+// key 的长度范围，随机 key 和自定义 key 都需要满足
+const (
+	MinKeyLength = 4
+	MaxKeyLength = 64
+)
+
+// key 只能包含字母、数字、'-' 和 '_'，可以直接放在 URL 路径中
+var keyPattern = regexp.MustCompile(fmt.Sprintf(`^[A-Za-z0-9_-]{%d,%d}$`, MinKeyLength, MaxKeyLength))
+
+// 内置保留字，与路由和前端页面的路径冲突，或容易被误认为官方页面
+var reservedKeys = []string{
+	"about", "admin", "api", "assets", "attachments", "auth", "docs", "embed",
+	"health", "help", "html", "languages", "livez", "login", "logout", "metrics",
+	"new", "once", "paste", "raw", "readyz", "report", "static", "uploads", "v1",
+}
+
+// IsValidKey 判断 key 的字符和长度是否合法
+func IsValidKey(key string) bool {
+	return keyPattern.MatchString(key)
+}
+
+// IsReservedKey 判断 key 是否为内置或 paste.key.reserved 中配置的保留字，不区分大小写
+func IsReservedKey(key string) bool {
+	key = strings.ToLower(key)
+	if slices.Contains(reservedKeys, key) {
+		return true
+	}
+	return slices.ContainsFunc(GetConfig().Paste.Key.Reserved, func(word string) bool {
+		return strings.ToLower(word) == key
+	})
+}
+
+// NewKey 按 paste.key 配置生成随机 key，不会生成保留字
+func NewKey() string {
+	config := GetConfig().Paste.Key
+	if config.Mode == KeyModeWords {
+		return newWordKey()
+	}
+	for {
+		if key := RandString(config.Alphabet, config.Length); !IsReservedKey(key) {
+			return key
+		}
+	}
+}
+
+// validateKeyAlphabet 校验随机 key 的字符集，字符必须合法且不能重复
+func validateKeyAlphabet(alphabet string) error {
+	if len(alphabet) < 2 {
+		return errors.New("must contain at least 2 characters")
+	}
+	for i, c := range []byte(alphabet) {
+		if !IsValidKey(strings.Repeat(string(c), MinKeyLength)) {
+			return fmt.Errorf("invalid character %q, only letters, digits, '-' and '_' are allowed", c)
+		}
+		if strings.IndexByte(alphabet[:i], c) >= 0 {
+			return fmt.Errorf("duplicate character %q", c)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+package util
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestIsValidKey(t *testing.T) {
+	tests := []struct {
+		key  string
+		want bool
+	}{
+		{"abcd", true},
+		{"My_Key-2024", true},
+		{strings.Repeat("a", MaxKeyLength), true},
+		{"abc", false},
+		{strings.Repeat("a", MaxKeyLength+1), false},
+		{"has space", false},
+		{"a/b/c/d", false},
+		{"中文中文", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := IsValidKey(tt.key); got != tt.want {
+			t.Errorf("IsValidKey(%q) = %v, want %v", tt.key, got, tt.want)
+		}
+	}
+}
+
+func TestIsReservedKey(t *testing.T) {
+	cfg := defaultConfig()
+	cfg.Paste.Key.Reserved = []string{"Company"}
+	withConfig(t, &cfg)
+
+	tests := []struct {
+		key  string
+		want bool
+	}{
+		{"admin", true},
+		{"ADMIN", true},
+		{"uploads", true},
+		{"company", true},
+		{"COMPANY", true},
+		{"my-paste", false},
+	}
+	for _, tt := range tests {
+		if got := IsReservedKey(tt.key); got != tt.want {
+			t.Errorf("IsReservedKey(%q) = %v, want %v", tt.key, got, tt.want)
+		}
+	}
+}
+
+func TestNewKey(t *testing.T) {
+	tests := []struct {
+		name     string
+		mode     string
+		alphabet string
+		length   int
+	}{
+		{"默认字符集", KeyModeRandom, DefaultKeyAlphabet, 10},
+		{"最短", KeyModeRandom, DefaultKeyAlphabet, MinKeyLength},
+		{"最长", KeyModeRandom, DefaultKeyAlphabet, MaxKeyLength},
+		{"小写字符集", KeyModeRandom, "abcdefghijkmnpqrstuvwxyz23456789", 8},
+		{"两个字符", KeyModeRandom, "-_", 6},
+		{"单词", KeyModeWords, DefaultKeyAlphabet, 10},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := defaultConfig()
+			cfg.Paste.Key.Mode = tt.mode
+			cfg.Paste.Key.Alphabet = tt.alphabet
+			cfg.Paste.Key.Length = tt.length
+			withConfig(t, &cfg)
+
+			for i := 0; i < 200; i++ {
+				key := NewKey()
+				if !IsValidKey(key) || IsReservedKey(key) {
+					t.Fatalf("NewKey() = %q, not a valid unreserved key", key)
+				}
+				if tt.mode == KeyModeWords {
+					continue
+				}
+				if len(key) != tt.length {
+					t.Fatalf("NewKey() = %q, want length %d", key, tt.length)
+				}
+				if strings.Trim(key, tt.alphabet) != "" {
+					t.Fatalf("NewKey() = %q, contains characters outside %q", key, tt.alphabet)
+				}
+			}
+		})
+	}
+}
+
+func TestValidateKeyAlphabet(t *testing.T) {
+	tests := []struct {
+		alphabet string
+		wantErr  bool
+	}{
+		{DefaultKeyAlphabet, false},
+		{"ab", false},
+		{"a-_", false},
+		{"a", true},
+		{"", true},
+		{"abca", true},
+		{"ab/", true},
+		{"ab c", true},
+		{"abé", true},
+	}
+	for _, tt := range tests {
+		if err := validateKeyAlphabet(tt.alphabet); (err != nil) != tt.wantErr {
+			t.Errorf("validateKeyAlphabet(%q) error = %v, wantErr %v", tt.alphabet, err, tt.wantErr)
+		}
+	}
+}
+
+// withConfig 在测试期间使用 cfg 作为当前配置，测试结束后恢复
+func withConfig(t *testing.T, cfg *Config) {
+	t.Helper()
+	old := currentConfig.Swap(cfg)
+	t.Cleanup(func() { currentConfig.Store(old) })
+}
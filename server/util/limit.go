@@ -1,10 +1,11 @@
 package util
 
 import (
+	"fmt"
+	"strings"
 	"sync"
 
 	log "github.com/sirupsen/logrus"
-	"github.com/spf13/viper"
 )
 
 type limitConfig struct {
@@ -13,10 +14,63 @@ type limitConfig struct {
 	Snippets_Count  int          `mapstructure:"snippets_count"`
 	Images_Size     int          `mapstructure:"images_size"`
 	Images_Count    int          `mapstructure:"images_count"`
+	Files_Size      int          `mapstructure:"files_size"`
+	Files_Count     int          `mapstructure:"files_count"`
 }
 
 var LimitConfig limitConfig
 
+// Limits 是 limitConfig 当前生效值的只读快照
+type Limits struct {
+	SnippetsLength int `mapstructure:"snippets_length" json:"snippets_length"`
+	SnippetsCount  int `mapstructure:"snippets_count" json:"snippets_count"`
+	ImagesSize     int `mapstructure:"images_size" json:"images_size"`
+	ImagesCount    int `mapstructure:"images_count" json:"images_count"`
+	FilesSize      int `mapstructure:"files_size" json:"files_size"`   // 图片以外的单个附件大小 MB
+	FilesCount     int `mapstructure:"files_count" json:"files_count"` // 图片以外的附件数量
+}
+
+// Validate 校验所有限制值是否为正数
+func (l Limits) Validate() error {
+	var invalid []string
+	if l.SnippetsLength <= 0 {
+		invalid = append(invalid, "snippets_length")
+	}
+	if l.SnippetsCount <= 0 {
+		invalid = append(invalid, "snippets_count")
+	}
+	if l.ImagesSize <= 0 {
+		invalid = append(invalid, "images_size")
+	}
+	if l.ImagesCount <= 0 {
+		invalid = append(invalid, "images_count")
+	}
+	if l.FilesSize <= 0 {
+		invalid = append(invalid, "files_size")
+	}
+	if l.FilesCount <= 0 {
+		invalid = append(invalid, "files_count")
+	}
+	if len(invalid) > 0 {
+		return fmt.Errorf("limit values must be positive: %s", strings.Join(invalid, ", "))
+	}
+	return nil
+}
+
+// Snapshot 返回当前生效的限制配置
+func (lc *limitConfig) Snapshot() Limits {
+	lc.mu.RLock()
+	defer lc.mu.RUnlock()
+	return Limits{
+		SnippetsLength: lc.Snippets_Length,
+		SnippetsCount:  lc.Snippets_Count,
+		ImagesSize:     lc.Images_Size,
+		ImagesCount:    lc.Images_Count,
+		FilesSize:      lc.Files_Size,
+		FilesCount:     lc.Files_Count,
+	}
+}
+
 func (lc *limitConfig) SnippetsLength() int {
 	lc.mu.RLock()
 	defer lc.mu.RUnlock()
@@ -41,51 +95,33 @@ func (lc *limitConfig) ImagesCount() int {
 	return lc.Images_Count
 }
 
-// InitializeLimits 从 Viper 加载 limit 配置
-func InitializeLimits() {
-	LimitConfig.mu.Lock()
-	defer LimitConfig.mu.Unlock()
-
-	newViper := viper.Sub("limit")
-	if newViper == nil {
-		log.Errorf("viper sub for 'limit' failed, using default values")
-		setDefaultLimits()
-		return
-	}
-
-	err := newViper.Unmarshal(&LimitConfig)
-	if err != nil {
-		log.Errorf("viper unmarshal for 'limit' failed: %+v, using default values", err)
-		setDefaultLimits()
-		return
-	}
-
-	// 即使成功解析了配置，也要检查是否需要设置默认值
-	ensureValidLimits()
+func (lc *limitConfig) FilesSize() int {
+	lc.mu.RLock()
+	defer lc.mu.RUnlock()
+	return lc.Files_Size
+}
 
-	log.Info("Limit configuration initialized successfully")
+func (lc *limitConfig) FilesCount() int {
+	lc.mu.RLock()
+	defer lc.mu.RUnlock()
+	return lc.Files_Count
 }
 
-// 设置默认限制值
-func setDefaultLimits() {
-	LimitConfig.Snippets_Length = 30000
-	LimitConfig.Snippets_Count = 10
-	LimitConfig.Images_Size = 10
-	LimitConfig.Images_Count = 3
+// apply 原子地替换当前生效的限制配置
+func (lc *limitConfig) apply(l Limits) {
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+	lc.Snippets_Length = l.SnippetsLength
+	lc.Snippets_Count = l.SnippetsCount
+	lc.Images_Size = l.ImagesSize
+	lc.Images_Count = l.ImagesCount
+	lc.Files_Size = l.FilesSize
+	lc.Files_Count = l.FilesCount
 }
 
-// 确保所有限制值都是有效的
-func ensureValidLimits() {
-	if LimitConfig.Snippets_Length <= 0 {
-		LimitConfig.Snippets_Length = 30000
-	}
-	if LimitConfig.Snippets_Count <= 0 {
-		LimitConfig.Snippets_Count = 10
-	}
-	if LimitConfig.Images_Size <= 0 {
-		LimitConfig.Images_Size = 10
-	}
-	if LimitConfig.Images_Count <= 0 {
-		LimitConfig.Images_Count = 3
-	}
+// InitializeLimits 从当前配置加载 limit 配置
+func InitializeLimits() {
+	LimitConfig.apply(GetConfig().Limit)
+
+	log.Info("Limit configuration initialized successfully")
 }
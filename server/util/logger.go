@@ -5,21 +5,72 @@ import (
 	"fmt"
 	"path"
 	"runtime"
+	"time"
 
 	"github.com/sirupsen/logrus"
+	"gopkg.in/natefinch/lumberjack.v2"
+
+	"paste.org.cn/paste/server/tracing"
+)
+
+// 定义常量 TRACEID，作为日志中 trace ID 字段的名称
+const TRACEID string = "TraceID"
+
+// 定义访问日志中由 handler 补充的字段，handler 通过 c.Set 写入 gin 上下文
+const (
+	PASTEKEY    string = "PasteKey"    // 本次请求创建或读取的分享 key
+	STORAGETYPE string = "StorageType" // 本次请求上传图片使用的存储类型
+)
+
+// 日志格式
+const (
+	LogFormatText = "text" // 文本格式，便于本地阅读
+	LogFormatJSON = "json" // JSON 格式，每行一条记录，便于日志系统解析
 )
 
 func init() {
 	logrus.SetReportCaller(true) // 让 logrus 在输出日志时自动包含调用日志记录函数的位置信息（文件名和行号）
 	// 设置日志的输出格式
-	logrus.SetFormatter(&logrus.TextFormatter{
-		FullTimestamp: true, // 日志中包含完整的时间戳
-		// 自定义方法，用于美化调用者信息的显示格式
-		CallerPrettyfier: func(f *runtime.Frame) (string, string) {
-			filename := path.Base(f.File)
-			return fmt.Sprintf("%s()", f.Function), fmt.Sprintf("%s:%d", filename, f.Line) // 函数名() 文件名:行号
-		},
-	})
+	logrus.SetFormatter(newLogFormatter(LogFormatText))
+}
+
+// callerPrettyfier 自定义方法，用于美化调用者信息的显示格式
+func callerPrettyfier(f *runtime.Frame) (string, string) {
+	filename := path.Base(f.File)
+	return fmt.Sprintf("%s()", f.Function), fmt.Sprintf("%s:%d", filename, f.Line) // 函数名() 文件名:行号
+}
+
+// newLogFormatter 根据日志格式创建 logrus 的 Formatter
+func newLogFormatter(format string) logrus.Formatter {
+	if format == LogFormatJSON {
+		return &logrus.JSONFormatter{
+			TimestampFormat:  time.RFC3339Nano,
+			CallerPrettyfier: callerPrettyfier,
+		}
+	}
+	return &logrus.TextFormatter{
+		FullTimestamp:    true, // 日志中包含完整的时间戳
+		CallerPrettyfier: callerPrettyfier,
+	}
+}
+
+// InitializeLogger 根据配置设置日志级别、格式和输出位置
+// 配置了 log.file 时输出到文件，并按大小自动切割
+func InitializeLogger(config LogConfig) {
+	level, _ := logrus.ParseLevel(config.Level) // 将配置中的日志级别从字符串转为log.level类型
+	logrus.SetLevel(level)
+	logrus.SetFormatter(newLogFormatter(config.Format))
+
+	if config.File != "" {
+		logrus.SetOutput(&lumberjack.Logger{
+			Filename:   config.File,
+			MaxSize:    config.MaxSize,
+			MaxBackups: config.MaxBackups,
+			MaxAge:     config.MaxAge,
+			Compress:   config.Compress,
+			LocalTime:  true,
+		})
+	}
 }
 
 // 定义一个非导出结构体，作为 context.WithValue 的键
@@ -33,7 +84,7 @@ func GetLogger(ctx context.Context) *logrus.Entry {
 	// 创建一个基于logrus标准（全局）日志记录器 (logrus.StandardLogger()) 的新 *logrus.Entry 并返回
 	if logger == nil {
 		return logrus.NewEntry(logrus.StandardLogger())
-	}	
+	}
 
 	return logger.(*logrus.Entry)
 }
@@ -56,6 +107,10 @@ func EnsureWithLogger(ctx context.Context) (context.Context, *logrus.Entry) {
 
 		// .WithField(REQID, rid) 为这个新的 logger 实例添加了一个固定的"ReqID"请求ID字段
 		newLoggerEntry := logrus.NewEntry(logrus.StandardLogger()).WithField(REQID, rid)
+		// 如果请求处于链路追踪中，同时记录 trace ID，便于将日志与链路关联
+		if traceID := tracing.TraceID(ctx); traceID != "" {
+			newLoggerEntry = newLoggerEntry.WithField(TRACEID, traceID)
+		}
 
 		// 将新创建的、带有请求 ID 的 logger 添加到 context 中
 		ctx = withLogger(ctx, newLoggerEntry)
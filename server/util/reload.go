@@ -0,0 +1,154 @@
+package util
+
+import (
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/fsnotify/fsnotify"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+// ReloadHook 在新配置校验通过并替换当前配置后被调用，用于将新配置应用到对应模块
+type ReloadHook func(cfg *Config)
+
+type reloadHook struct {
+	keys []string // 该钩子负责的配置项，支持前缀匹配，例如 "limit" 匹配 "limit.snippets_count"
+	hook ReloadHook
+}
+
+var (
+	reloadMu    sync.Mutex   // 保证同一时间只有一次重载
+	reloadHooks []reloadHook // 已注册的热更新钩子
+	hotKeys     []string     // 支持热更新的配置项，包括注册了钩子的配置项
+)
+
+func init() {
+	RegisterReloadHook([]string{"log.level", "log.format"}, func(cfg *Config) {
+		level, _ := log.ParseLevel(cfg.Log.Level)
+		log.SetLevel(level)
+		log.SetFormatter(newLogFormatter(cfg.Log.Format))
+	})
+	RegisterReloadHook([]string{"limit"}, func(cfg *Config) {
+		LimitConfig.apply(cfg.Limit)
+	})
+	RegisterReloadHook([]string{"auth"}, func(cfg *Config) {
+		AuthConfig.apply(cfg.Auth.Tokens)
+	})
+	// 图片处理、可续传上传、key 生成配置、公开地址和嵌入配置在每次使用时通过 GetConfig 读取
+	RegisterHotKeys("image", "upload", "paste.key", "server.public_url", "embed")
+}
+
+// RegisterReloadHook 注册一个配置热更新钩子，keys 中任意配置项发生变化时调用 hook，keys 同时被登记为支持热更新
+func RegisterReloadHook(keys []string, hook ReloadHook) {
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+	reloadHooks = append(reloadHooks, reloadHook{keys: keys, hook: hook})
+	hotKeys = append(hotKeys, keys...)
+}
+
+// RegisterHotKeys 登记支持热更新但不需要钩子的配置项，这些配置项在每次使用时通过 GetConfig 读取，
+// 未登记的配置项变化时提示需要重启
+func RegisterHotKeys(keys ...string) {
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+	hotKeys = append(hotKeys, keys...)
+}
+
+// configChange 描述一个配置项的变化
+type configChange struct {
+	key           string
+	before, after any
+}
+
+// ReloadConfig 重新读取配置并应用所有热更新项
+// 新配置校验失败时放弃本次重载，保留之前的配置
+func ReloadConfig() error {
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+
+	next, _, err := ReadConfig(configFile)
+	if err == nil {
+		err = next.Validate()
+	}
+	if err != nil {
+		log.Errorf("重载配置失败，保留之前的配置: %v", err)
+		return err
+	}
+
+	current := GetConfig()
+	changes := diffConfig(current, next)
+	if len(changes) == 0 {
+		log.Info("配置已重载，没有发生变更")
+		return nil
+	}
+
+	// 先替换当前配置，钩子以及钩子触发的代码通过 GetConfig 读取到的都是新配置
+	currentConfig.Store(next)
+	for _, h := range reloadHooks {
+		if slices.ContainsFunc(changes, func(c configChange) bool { return matchKeys(h.keys, c.key) }) {
+			h.hook(next)
+		}
+	}
+
+	for _, c := range changes {
+		if matchKeys(hotKeys, c.key) {
+			log.Infof("配置变更: %s: %v -> %v", c.key, c.before, c.after)
+		} else {
+			log.Warnf("配置变更: %s: %v -> %v，需要重启才能生效", c.key, c.before, c.after)
+		}
+	}
+	log.Infof("配置已重载，共 %d 项变更", len(changes))
+	return nil
+}
+
+// WatchConfig 使用 viper 的文件监听，在配置文件变化时自动重载
+// 使用独立的 viper 实例监听，新配置由 ReloadConfig 读取并校验
+func WatchConfig() {
+	if configFile == "" {
+		log.Warn("未使用配置文件，跳过配置文件监听")
+		return
+	}
+
+	watcher := viper.New()
+	watcher.SetConfigFile(configFile)
+	if err := watcher.ReadInConfig(); err != nil {
+		log.Errorf("监听配置文件失败: %+v", err)
+		return
+	}
+	watcher.OnConfigChange(func(e fsnotify.Event) {
+		log.Infof("检测到配置文件变化: %s", e.Name)
+		_ = ReloadConfig()
+	})
+	watcher.WatchConfig()
+	log.Infof("开始监听配置文件: %s", configFile)
+}
+
+// diffConfig 比较新旧配置，返回发生变化的配置项，密钥类配置以掩码输出
+func diffConfig(before, after *Config) []configChange {
+	rawBefore, rawAfter := flattenConfig(reflect.ValueOf(*before), ""), flattenConfig(reflect.ValueOf(*after), "")
+	maskedBefore := flattenConfig(reflect.ValueOf(*before.Masked()), "")
+	maskedAfter := flattenConfig(reflect.ValueOf(*after.Masked()), "")
+
+	var changes []configChange
+	for key, value := range rawAfter {
+		if reflect.DeepEqual(rawBefore[key], value) {
+			continue
+		}
+		changes = append(changes, configChange{key: key, before: maskedBefore[key], after: maskedAfter[key]})
+	}
+	slices.SortFunc(changes, func(a, b configChange) int { return strings.Compare(a.key, b.key) })
+	return changes
+}
+
+// matchKeys 判断配置项是否属于 keys 中的任意一项
+func matchKeys(keys []string, key string) bool {
+	for _, k := range keys {
+		if key == k || strings.HasPrefix(key, k+".") {
+			return true
+		}
+	}
+	return false
+}
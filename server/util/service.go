@@ -6,28 +6,24 @@ import (
 	"net"
 	"net/http"
 	"os"
+	"sync/atomic"
 	"time"
 
 	log "github.com/sirupsen/logrus" // 用logrus第三方开源库来替换标准库log包，logrus兼容标准库log包的所有API
-	"github.com/spf13/viper"
 )
 
-// 加载配置
-func LoadConfig(configName string) {
-	viper.SetConfigName(configName)
-	viper.AddConfigPath(".")
-	err := viper.ReadInConfig()
-	if err != nil {
-		log.Fatalf("Fatal error config file: %+v", err)
-	}
+// ready 表示服务是否可以接收流量，由 /readyz 使用
+var ready atomic.Bool
 
-	level, _ := log.ParseLevel(viper.GetString("log.level")) // 将从配置文件中获取到的日志从字符串转为log.level类型
-	log.SetLevel(level)
+// IsReady 返回服务是否可以接收流量，关闭过程中返回 false
+func IsReady() bool {
+	return ready.Load()
 }
 
 // 启动服务器
 func RunServer(srv *http.Server) {
 	log.Printf("Starting server at %s", srv.Addr)
+	ready.Store(true)
 	if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 		log.Fatalf("listen: %+v", err)
 	}
@@ -36,6 +32,13 @@ func RunServer(srv *http.Server) {
 
 // ShutdownServer 优雅地关闭服务器，等待当前活动连接完成
 func ShutdownServer(srv *http.Server) {
+	// 先让 /readyz 返回失败，等待负载均衡摘除流量后再关闭，避免新请求被拒绝
+	ready.Store(false)
+	if delay := time.Duration(GetConfig().Server.DrainDelay) * time.Second; delay > 0 {
+		log.Infof("等待 %s 摘除流量", delay)
+		time.Sleep(delay)
+	}
+
 	// 创建一个 5 秒超时的上下文，如果5秒内所有连接没有关闭，强制关闭服务器
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
@@ -0,0 +1,53 @@
+package util
+
+import (
+	"crypto/rand"
+	"fmt"
+	"math/big"
+)
+
+// 单词 key 使用的形容词，都是简单、易读且不易混淆的英文单词
+var keyAdjectives = []string{
+	"able", "amber", "ample", "azure", "basic", "blue", "bold", "brave", "brief", "bright",
+	"brisk", "calm", "candid", "cheery", "civil", "clean", "clear", "clever", "cool", "cosy",
+	"crisp", "curly", "daring", "deep", "eager", "early", "easy", "fair", "fancy", "fast",
+	"fine", "firm", "fluffy", "fond", "free", "fresh", "frosty", "gentle", "giant", "glad",
+	"golden", "grand", "green", "happy", "hardy", "honest", "humble", "jolly", "keen", "kind",
+	"large", "lively", "lucky", "magic", "mellow", "merry", "mighty", "mild", "modest", "neat",
+	"nimble", "noble", "plain", "polite", "proud", "purple", "quick", "quiet", "rapid", "ready",
+	"red", "rosy", "round", "royal", "shiny", "silent", "silver", "simple", "sleek", "smart",
+	"snowy", "solid", "sunny", "super", "sweet", "swift", "tidy", "tiny", "upbeat", "vast",
+	"vivid", "warm", "wavy", "wild", "wise", "witty", "young", "zany", "zesty", "zippy",
+}
+
+// 单词 key 使用的动物名
+var keyAnimals = []string{
+	"alpaca", "badger", "beaver", "bison", "bobcat", "camel", "canary", "cheetah", "chicken", "cobra",
+	"condor", "cougar", "coyote", "crane", "crow", "dingo", "dolphin", "donkey", "dove", "duck",
+	"eagle", "egret", "falcon", "ferret", "finch", "flamingo", "fox", "gazelle", "gecko", "gibbon",
+	"giraffe", "goat", "goose", "gopher", "gorilla", "hamster", "hare", "hawk", "hedgehog", "heron",
+	"hippo", "horse", "hyena", "ibis", "iguana", "impala", "jackal", "jaguar", "kiwi", "koala",
+	"lemur", "leopard", "lion", "llama", "lobster", "lynx", "macaw", "magpie", "marmot", "meerkat",
+	"mink", "mole", "moose", "newt", "ocelot", "octopus", "orca", "osprey", "otter", "owl",
+	"panda", "parrot", "pelican", "penguin", "pigeon", "puffin", "puma", "quail", "rabbit", "raccoon",
+	"raven", "robin", "salmon", "seal", "shark", "sloth", "sparrow", "squid", "stork", "swan",
+	"tapir", "tiger", "toucan", "turtle", "walrus", "weasel", "whale", "wolf", "wombat", "zebra",
+}
+
+// newWordKey 生成 "形容词-形容词-动物-四位数字" 形式的 key，例如 brave-quiet-otter-4821，便于口头或在屏幕上分享
+// 两个形容词不重复，共约 89 亿种组合（约 33 位熵），仍远低于随机 key，只适合不敏感的内容
+func newWordKey() string {
+	first := randIntn(len(keyAdjectives))
+	// 从其余形容词中选择第二个，避免 brave-brave 这样的重复
+	second := (first + 1 + randIntn(len(keyAdjectives)-1)) % len(keyAdjectives)
+	return fmt.Sprintf("%s-%s-%s-%d", keyAdjectives[first], keyAdjectives[second], keyAnimals[randIntn(len(keyAnimals))], 1000+randIntn(9000))
+}
+
+// randIntn 使用 crypto/rand 返回 [0, n) 范围内的随机整数
+func randIntn(n int) int {
+	v, err := rand.Int(rand.Reader, big.NewInt(int64(n)))
+	if err != nil {
+		panic(err) // crypto/rand 不会返回错误
+	}
+	return int(v.Int64())
+}
@@ -0,0 +1,49 @@
+package util
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestWordLists(t *testing.T) {
+	for name, words := range map[string][]string{"keyAdjectives": keyAdjectives, "keyAnimals": keyAnimals} {
+		seen := make(map[string]bool, len(words))
+		for _, word := range words {
+			// 单词之间用 '-' 连接，单词本身只能是小写字母，否则无法按 '-' 拆分
+			if word == "" || strings.Trim(word, "abcdefghijklmnopqrstuvwxyz") != "" {
+				t.Errorf("%s: invalid word %q", name, word)
+			}
+			if seen[word] {
+				t.Errorf("%s: duplicate word %q", name, word)
+			}
+			seen[word] = true
+		}
+	}
+}
+
+func TestNewWordKey(t *testing.T) {
+	for i := 0; i < 2000; i++ {
+		key := newWordKey()
+		parts := strings.Split(key, "-")
+		if len(parts) != 4 {
+			t.Fatalf("newWordKey() = %q, want adjective-adjective-animal-number", key)
+		}
+		if !slices.Contains(keyAdjectives, parts[0]) || !slices.Contains(keyAdjectives, parts[1]) {
+			t.Fatalf("newWordKey() = %q, unknown adjective", key)
+		}
+		if parts[0] == parts[1] {
+			t.Fatalf("newWordKey() = %q, repeated adjective", key)
+		}
+		if !slices.Contains(keyAnimals, parts[2]) {
+			t.Fatalf("newWordKey() = %q, unknown animal", key)
+		}
+		if n, err := strconv.Atoi(parts[3]); err != nil || n < 1000 || n > 9999 {
+			t.Fatalf("newWordKey() = %q, want a four-digit number", key)
+		}
+		if !IsValidKey(key) {
+			t.Fatalf("newWordKey() = %q, not a valid key", key)
+		}
+	}
+}
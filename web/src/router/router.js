@@ -17,7 +17,7 @@ const router = new Router({
     base: "/",
     routes: [
         {
-            path: "/:key(0{0}|[0-9a-zA-Z]{10})",
+            path: "/:key(0{0}|[0-9a-zA-Z_-]{4,64})",
             name: "index",
             component: Index
         },